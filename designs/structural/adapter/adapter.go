@@ -1,6 +1,12 @@
 package adapter
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
 
 // Let's break down the key components of the Adapter Pattern:
 //
@@ -17,12 +23,30 @@ type LegacyPrinter interface {
 	Print(s string) string
 }
 
+// LegacyPrinterFunc lets an ordinary function be used as a LegacyPrinter
+type LegacyPrinterFunc func(s string) string
+
+func (f LegacyPrinterFunc) Print(s string) string {
+	return f(s)
+}
+
 // Existing implementation of the LegacyPrinter interface
-type MyLegacyPrinter struct{}
+type MyLegacyPrinter struct {
+	out io.Writer
+}
+
+// NewMyLegacyPrinter creates a legacy printer that writes to out. The zero value writes to os.Stdout.
+func NewMyLegacyPrinter(out io.Writer) *MyLegacyPrinter {
+	return &MyLegacyPrinter{out: out}
+}
 
 func (l *MyLegacyPrinter) Print(s string) string {
 	newMsg := fmt.Sprintf("Legacy Printer: %s", s)
-	fmt.Println(newMsg)
+	out := l.out
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintln(out, newMsg)
 	return newMsg
 }
 
@@ -35,14 +59,41 @@ type ModernPrinter interface {
 type PrinterAdapter struct {
 	legacyPrinter LegacyPrinter
 	msg           string
+	out           io.Writer
+}
+
+// AdapterOption configures a PrinterAdapter
+type AdapterOption func(*PrinterAdapter)
+
+// WithOutput sets where the fallback MyLegacyPrinter writes when the adapter has no legacy printer of its own
+func WithOutput(out io.Writer) AdapterOption {
+	return func(p *PrinterAdapter) {
+		p.out = out
+	}
+}
+
+// NewPrinterAdapter creates an adapter that prints msg through the legacy printer. A nil legacy printer falls back to
+// MyLegacyPrinter when printing.
+func NewPrinterAdapter(legacyPrinter LegacyPrinter, msg string, opts ...AdapterOption) *PrinterAdapter {
+	p := &PrinterAdapter{legacyPrinter: legacyPrinter, msg: msg}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 func (p *PrinterAdapter) PrintStored() string {
-	// Check if the legacy printer is null (nil) and create a new instance if it is
-	if p.legacyPrinter == nil {
-		p.legacyPrinter = &MyLegacyPrinter{}
+	return p.print(p.msg)
+}
+
+func (p *PrinterAdapter) print(msg string) string {
+	// Check if the legacy printer is null (nil) and use a new instance if it is. The adapter itself is left alone, so
+	// concurrent calls do not race on it.
+	lp := p.legacyPrinter
+	if lp == nil {
+		lp = NewMyLegacyPrinter(p.out)
 	}
-	return p.legacyPrinter.Print(p.msg)
+	return lp.Print(msg)
 }
 
 func main() {
@@ -53,4 +104,76 @@ func main() {
 	}
 
 	adapter.PrintStored() // Output: Legacy Printer: Hello, World!
+
+	// Let a registry find the adapter for any LegacyPrinter
+	registry := NewRegistry()
+	RegisterPrinterAdapters(registry, "Hello, Registry!")
+	if modern, err := Resolve[ModernPrinter](registry, legacyPrinter); err == nil {
+		modern.PrintStored() // Output: Legacy Printer: Hello, Registry!
+	}
+	_, err := Resolve[ModernPrinter](registry, 42)
+	fmt.Println(err) // Output: adapter: no adapter path from int to adapter.ModernPrinter
+
+	// Adapt back the other way, and stack both directions without doubling the prefix
+	back := NewLegacyAdapter(PrinterFactory(legacyPrinter))
+	back.Print("Round trip")                         // Output: Legacy Printer: Round trip
+	NewPrinterAdapter(back, "Stacked").PrintStored() // Output: Legacy Printer: Stacked
+	if unwrapped, err := ToLegacy(adapter); err == nil {
+		unwrapped.Print("Unwrapped") // Output: Legacy Printer: Unwrapped
+	}
+
+	// Capture the output instead of printing it
+	captured := &Buffer{}
+	NewPrinterAdapter(nil, "Captured", WithOutput(captured)).PrintStored()
+	fmt.Println(captured.Lines()) // Output: [Legacy Printer: Captured]
+
+	// Push many messages through the legacy printer in batches
+	captured.Reset()
+	batcher := NewBatchAdapter(NewMyLegacyPrinter(captured), BatchOptions{BatchSize: 2})
+	results, _ := batcher.PrintAll(context.Background(), []string{"one", "two", "three"})
+	fmt.Println(len(results), results[2].Output) // Output: 3 Legacy Printer: three
+
+	// A legacy backend that fails by convention: an "ERROR:" marker, no output, or a panic
+	backend := LegacyPrinterFunc(func(s string) string {
+		switch s {
+		case "offline":
+			return "ERROR: printer offline"
+		case "blank":
+			return ""
+		case "jam":
+			panic("paper jam")
+		}
+		return "Legacy Printer: " + s
+	})
+	checked := NewCheckedPrinter(backend)
+	for _, msg := range []string{"ok", "offline", "blank", "jam"} {
+		_, err := NewFallibleAdapter(checked, msg).PrintStored()
+		var panicErr *PanicError
+		fmt.Println(err == nil, errors.Is(err, ErrLegacyFailure), errors.Is(err, ErrEmptyOutput), errors.As(err, &panicErr))
+	}
+	// Output:
+	// true false false false
+	// false true false false
+	// false false true false
+	// false false false true
+
+	// Serve the printer over HTTP and call it through a client-side adapter
+	handler := NewPrinterHandler(PrinterFactory(NewMyLegacyPrinter(io.Discard)))
+	_ = handler // http.ListenAndServe(":8080", handler)
+	var remote ModernPrinter = NewRemotePrinter("http://localhost:8080", "Over the wire")
+	_ = remote // remote.PrintStored() returns "Legacy Printer: Over the wire"
+
+	// Render a template with structured data before printing
+	invoice := struct {
+		ID   int
+		Date string
+	}{ID: 42, Date: "2024-07-01"}
+	templated, err := NewTemplateAdapter(legacyPrinter, "Invoice {{.ID}} due {{.Date}}", invoice)
+	if err != nil {
+		panic(err)
+	}
+	var fallible FallibleModernPrinter = templated
+	fallible.PrintStored() // Output: Legacy Printer: Invoice 42 due 2024-07-01
+	_, err = templated.PrintWith(map[string]any{"ID": 43})
+	fmt.Println(err != nil) // Output: true (no .Date)
 }
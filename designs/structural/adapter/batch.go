@@ -0,0 +1,191 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+)
+
+// PrinterAdapter prints one stored message per call. BatchAdapter is the streaming form of the same adapter: it reads
+// messages from a channel, groups them into batches and hands each batch to the LegacyPrinter, reporting one Result
+// per message. Legacy printers that can print a whole batch at once implement BatchPrinter; the others are called once
+// per message. Failures are translated by a CheckedPrinter, so Result.Err is a *LegacyError, or ctx.Err() for a message
+// that was not printed because the context was done.
+//
+// Because of that translation, some output that PrinterAdapter returns as is fails here: empty output gives
+// ErrEmptyOutput, and output starting with a sentinel's prefix, "ERROR:" by default, gives that sentinel's error.
+//
+// Results are sent on a buffered channel. When the consumer stops reading, the adapter stops reading input, so a slow
+// consumer pushes back on the producer instead of queueing without bound.
+
+// BatchPrinter is an optional fast path for legacy printers that can print many messages in one call.
+// It returns one output per message, in order.
+type BatchPrinter interface {
+	PrintBatch(msgs []string) []string
+}
+
+// Result is the outcome of printing one message
+type Result struct {
+	// Seq is the position of the message in the input stream, starting at 0
+	Seq    int
+	Msg    string
+	Output string
+	Err    error
+}
+
+// BatchOptions configures a BatchAdapter
+type BatchOptions struct {
+	// BatchSize is the maximum number of messages per batch. Defaults to 64.
+	BatchSize int
+	// FlushInterval flushes a partial batch once its first message has waited this long. Zero only flushes full
+	// batches and the last one.
+	FlushInterval time.Duration
+	// QueueSize is the number of results buffered for the consumer. Defaults to BatchSize.
+	QueueSize int
+	// Sentinels are passed to NewCheckedPrinter
+	Sentinels []Sentinel
+}
+
+// BatchAdapter adapts a LegacyPrinter to batched, streaming printing
+type BatchAdapter struct {
+	checked *CheckedPrinter
+	opts    BatchOptions
+}
+
+// NewBatchAdapter creates a batch adapter for the legacy printer
+func NewBatchAdapter(legacyPrinter LegacyPrinter, opts BatchOptions) *BatchAdapter {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 64
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = opts.BatchSize
+	}
+	return &BatchAdapter{checked: NewCheckedPrinter(legacyPrinter, opts.Sentinels...), opts: opts}
+}
+
+// Stream prints every message received on in and sends one Result per message on the returned channel, in input
+// order. The channel is closed once in is closed and drained, or once ctx is done. ctx is checked before every batch:
+// a message that was printed is reported with its outcome even if ctx is done by the time it is sent, and a message
+// that was read but not printed is reported with ctx.Err(). No result is dropped, so read the channel until it is
+// closed.
+func (a *BatchAdapter) Stream(ctx context.Context, in <-chan string) <-chan Result {
+	out := make(chan Result, a.opts.QueueSize)
+	go func() {
+		defer close(out)
+		var (
+			batch    []string
+			firstSeq int
+			seq      int
+			timer    *time.Timer
+			timeout  <-chan time.Time
+		)
+		stopTimer := func() {
+			if timer != nil {
+				timer.Stop()
+				timer, timeout = nil, nil
+			}
+		}
+		defer stopTimer()
+		// cancel reports the unprinted batch with ctx.Err()
+		cancel := func() {
+			for i, msg := range batch {
+				out <- Result{Seq: firstSeq + i, Msg: msg, Err: ctx.Err()}
+			}
+			batch = nil
+		}
+		// flush prints the batch unless ctx is done, so it returns false when the stream must stop
+		flush := func() bool {
+			stopTimer()
+			if ctx.Err() != nil {
+				cancel()
+				return false
+			}
+			for _, r := range a.printBatch(firstSeq, batch) {
+				out <- r
+			}
+			batch = nil
+			return true
+		}
+		for {
+			// select picks at random among ready cases, so a done ctx is checked first
+			if ctx.Err() != nil {
+				cancel()
+				return
+			}
+			select {
+			case <-ctx.Done():
+				cancel()
+				return
+			case <-timeout:
+				if !flush() {
+					return
+				}
+			case msg, ok := <-in:
+				if !ok {
+					if len(batch) > 0 {
+						flush()
+					}
+					return
+				}
+				if len(batch) == 0 {
+					firstSeq = seq
+					if a.opts.FlushInterval > 0 {
+						timer = time.NewTimer(a.opts.FlushInterval)
+						timeout = timer.C
+					}
+				}
+				batch = append(batch, msg)
+				seq++
+				if len(batch) >= a.opts.BatchSize && !flush() {
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// PrintAll prints the messages and returns their results in order. It returns ctx.Err() if ctx is done before every
+// message was printed, together with the results collected so far, including those reported with ctx.Err().
+func (a *BatchAdapter) PrintAll(ctx context.Context, msgs []string) ([]Result, error) {
+	in := make(chan string)
+	go func() {
+		defer close(in)
+		for _, msg := range msgs {
+			select {
+			case in <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	results := make([]Result, 0, len(msgs))
+	for r := range a.Stream(ctx, in) {
+		results = append(results, r)
+	}
+	return results, cancelErr(ctx, results, len(msgs))
+}
+
+// cancelErr returns ctx.Err() if ctx is done and some of the n messages are missing from results or were reported with
+// ctx.Err(). Stream reports an unflushed batch that way, so a full set of results can still be a cancelled one.
+func cancelErr(ctx context.Context, results []Result, n int) error {
+	err := ctx.Err()
+	if err == nil {
+		return nil
+	}
+	if len(results) < n || slices.ContainsFunc(results, func(r Result) bool { return errors.Is(r.Err, err) }) {
+		return err
+	}
+	return nil
+}
+
+// printBatch prints one batch and reports a Result per message
+func (a *BatchAdapter) printBatch(firstSeq int, batch []string) []Result {
+	outs, errs := a.checked.PrintBatch(batch)
+	results := make([]Result, len(batch))
+	for i, msg := range batch {
+		results[i] = Result{Seq: firstSeq + i, Msg: msg, Output: outs[i], Err: errs[i]}
+	}
+	return results
+}
@@ -0,0 +1,239 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPrintAll(t *testing.T) {
+	var out Buffer
+	a := NewBatchAdapter(NewMyLegacyPrinter(&out), BatchOptions{BatchSize: 2})
+	results, err := a.PrintAll(context.Background(), []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range results {
+		if r.Seq != i || r.Err != nil || r.Output != "Legacy Printer: "+r.Msg {
+			t.Errorf("result %d = %+v", i, r)
+		}
+	}
+	if len(out.Lines()) != 3 {
+		t.Errorf("printed %q", out.Lines())
+	}
+}
+
+func TestPrintAllCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a := NewBatchAdapter(NewMyLegacyPrinter(&Buffer{}), BatchOptions{})
+	if _, err := a.PrintAll(ctx, []string{"a", "b"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestCancelErr(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	printed := []Result{{Seq: 0, Output: "a"}, {Seq: 1, Output: "b"}}
+	// the last message was still in a batch when ctx was done
+	unflushed := []Result{{Seq: 0, Output: "a"}, {Seq: 1, Err: context.Canceled}}
+	failed := []Result{{Seq: 0, Output: "a"}, {Seq: 1, Err: &LegacyError{Err: ErrEmptyOutput}}}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		results []Result
+		want    error
+	}{
+		{"all printed", context.Background(), printed, nil},
+		{"all printed before the cancel", cancelled, printed, nil},
+		{"missing results", cancelled, printed[:1], context.Canceled},
+		{"unflushed batch", cancelled, unflushed, context.Canceled},
+		{"printer failure", cancelled, failed, nil},
+	}
+	for _, tt := range tests {
+		if err := cancelErr(tt.ctx, tt.results, 2); err != tt.want {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// recordingPrinter is a BatchPrinter that remembers the batches it printed
+type recordingPrinter struct {
+	mu      sync.Mutex
+	batches [][]string
+	onPrint func()
+}
+
+func (p *recordingPrinter) Print(s string) string {
+	return p.PrintBatch([]string{s})[0]
+}
+
+func (p *recordingPrinter) PrintBatch(msgs []string) []string {
+	p.mu.Lock()
+	p.batches = append(p.batches, slices.Clone(msgs))
+	p.mu.Unlock()
+	if p.onPrint != nil {
+		p.onPrint()
+	}
+	outs := make([]string, len(msgs))
+	for i, msg := range msgs {
+		outs[i] = "printed " + msg
+	}
+	return outs
+}
+
+func (p *recordingPrinter) printed() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, b := range p.batches {
+		n += len(b)
+	}
+	return n
+}
+
+func collect(results <-chan Result) []Result {
+	var all []Result
+	for r := range results {
+		all = append(all, r)
+	}
+	return all
+}
+
+func TestStreamBatches(t *testing.T) {
+	p := &recordingPrinter{}
+	in := make(chan string)
+	results := NewBatchAdapter(p, BatchOptions{BatchSize: 2}).Stream(context.Background(), in)
+	go func() {
+		for _, msg := range []string{"a", "b", "c", "d", "e"} {
+			in <- msg
+		}
+		close(in)
+	}()
+	all := collect(results)
+	for i, r := range all {
+		if r.Seq != i || r.Err != nil || r.Output != "printed "+r.Msg {
+			t.Errorf("result %d = %+v", i, r)
+		}
+	}
+	want := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}
+	if !slices.EqualFunc(p.batches, want, slices.Equal) {
+		t.Errorf("batches = %q, want %q", p.batches, want)
+	}
+}
+
+func TestStreamFlushInterval(t *testing.T) {
+	p := &recordingPrinter{}
+	in := make(chan string)
+	results := NewBatchAdapter(p, BatchOptions{BatchSize: 10, FlushInterval: 10 * time.Millisecond}).Stream(context.Background(), in)
+	in <- "a"
+	in <- "b"
+	// the partial batch is flushed by the timer while in is still open
+	for i := range 2 {
+		select {
+		case r := <-results:
+			if r.Seq != i || r.Err != nil {
+				t.Errorf("result %d = %+v", i, r)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("partial batch was not flushed")
+		}
+	}
+	close(in)
+	if rest := collect(results); len(rest) != 0 {
+		t.Errorf("extra results %+v", rest)
+	}
+	if len(p.batches) != 1 || len(p.batches[0]) != 2 {
+		t.Errorf("batches = %q", p.batches)
+	}
+}
+
+func TestStreamBackPressure(t *testing.T) {
+	p := &recordingPrinter{}
+	in := make(chan string)
+	results := NewBatchAdapter(p, BatchOptions{BatchSize: 1, QueueSize: 1}).Stream(context.Background(), in)
+	// one result fits in the queue and one waits to be sent, then the adapter stops reading
+	sent := 0
+	for sent < 5 {
+		select {
+		case in <- "x":
+			sent++
+			continue
+		case <-time.After(50 * time.Millisecond):
+		}
+		break
+	}
+	if sent != 2 {
+		t.Errorf("the adapter accepted %d messages without a reader, want 2", sent)
+	}
+	close(in)
+	if all := collect(results); len(all) != sent {
+		t.Errorf("got %d results for %d messages", len(all), sent)
+	}
+}
+
+func TestStreamCancelledBeforeStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range 200 {
+		p := &recordingPrinter{}
+		in := make(chan string, 3)
+		in <- "a"
+		in <- "b"
+		in <- "c"
+		close(in)
+		for _, r := range collect(NewBatchAdapter(p, BatchOptions{BatchSize: 1}).Stream(ctx, in)) {
+			if !errors.Is(r.Err, context.Canceled) {
+				t.Fatalf("result %+v, want context.Canceled", r)
+			}
+		}
+		if p.printed() != 0 {
+			t.Fatalf("printed %d messages after ctx was done", p.printed())
+		}
+	}
+}
+
+func TestStreamCancelledMidBatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := &recordingPrinter{}
+	in := make(chan string)
+	results := NewBatchAdapter(p, BatchOptions{BatchSize: 3}).Stream(ctx, in)
+	in <- "a"
+	in <- "b"
+	cancel()
+	all := collect(results)
+	if len(all) != 2 || p.printed() != 0 {
+		t.Fatalf("got %+v with %d printed", all, p.printed())
+	}
+	for i, r := range all {
+		if r.Seq != i || !errors.Is(r.Err, context.Canceled) || r.Output != "" {
+			t.Errorf("result %d = %+v, want an unprinted message", i, r)
+		}
+	}
+}
+
+func TestStreamKeepsPrintedResultsAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// the printer cancels while it prints, so ctx is done before any result is sent
+	p := &recordingPrinter{onPrint: cancel}
+	in := make(chan string)
+	results := NewBatchAdapter(p, BatchOptions{BatchSize: 2, QueueSize: 1}).Stream(ctx, in)
+	in <- "a"
+	in <- "b"
+	all := collect(results)
+	if len(all) != 2 {
+		t.Fatalf("got %d results, want both printed messages", len(all))
+	}
+	for i, r := range all {
+		if r.Err != nil || r.Output != "printed "+r.Msg {
+			t.Errorf("result %d = %+v, want the printed output", i, r)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+// LegacyPrinter and ModernPrinter cannot report failure, so legacy backends signal it by convention: a marker in the
+// output, an empty result, or a panic. CheckedPrinter translates those conventions into Go errors, and the Fallible
+// interfaces carry them to the caller.
+//
+// Every translated error is a *LegacyError, which wraps the cause: ErrEmptyOutput, the error of the matching Sentinel,
+// or a *PanicError.
+
+// FallibleLegacyPrinter is LegacyPrinter with failure reporting
+type FallibleLegacyPrinter interface {
+	Print(s string) (string, error)
+}
+
+// FallibleModernPrinter is ModernPrinter with failure reporting
+type FallibleModernPrinter interface {
+	PrintStored() (string, error)
+}
+
+var (
+	// ErrEmptyOutput is the cause when the legacy printer returns nothing but whitespace
+	ErrEmptyOutput = errors.New("adapter: legacy printer returned no output")
+	// ErrLegacyFailure is the cause for the default sentinel
+	ErrLegacyFailure = errors.New("adapter: legacy printer reported failure")
+)
+
+// Sentinel maps a failure marker to an error: output starting with Prefix fails with Err
+type Sentinel struct {
+	Prefix string
+	Err    error
+}
+
+// DefaultSentinels treats output starting with "ERROR:" as a failure
+var DefaultSentinels = []Sentinel{{Prefix: "ERROR:", Err: ErrLegacyFailure}}
+
+// LegacyError is a failure of the legacy printer
+type LegacyError struct {
+	// Op is the operation that failed, "print" or "print batch"
+	Op string
+	// Msg is the message being printed
+	Msg string
+	// Output is what the legacy printer returned, if anything
+	Output string
+	Err    error
+}
+
+func (e *LegacyError) Error() string {
+	return fmt.Sprintf("adapter: %s %q: %v", e.Op, e.Msg, e.Err)
+}
+
+func (e *LegacyError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is a panic recovered from a printer
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("printer panicked: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// CheckedPrinter adapts LegacyPrinter to FallibleLegacyPrinter
+type CheckedPrinter struct {
+	legacyPrinter LegacyPrinter
+	sentinels     []Sentinel
+}
+
+// NewCheckedPrinter creates a checked printer. Without sentinels, DefaultSentinels are used.
+func NewCheckedPrinter(legacyPrinter LegacyPrinter, sentinels ...Sentinel) *CheckedPrinter {
+	if len(sentinels) == 0 {
+		sentinels = DefaultSentinels
+	}
+	return &CheckedPrinter{legacyPrinter: legacyPrinter, sentinels: sentinels}
+}
+
+func (c *CheckedPrinter) Print(s string) (out string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			out, err = "", &LegacyError{Op: "print", Msg: s, Err: &PanicError{Value: r, Stack: debug.Stack()}}
+		}
+	}()
+	out = c.legacyPrinter.Print(s)
+	if err := c.check("print", s, out); err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// PrintBatch prints the messages through PrintBatch when the legacy printer is a BatchPrinter, and one by one
+// otherwise. It returns one output and one error per message.
+func (c *CheckedPrinter) PrintBatch(msgs []string) ([]string, []error) {
+	outs := make([]string, len(msgs))
+	errs := make([]error, len(msgs))
+	bp, ok := c.legacyPrinter.(BatchPrinter)
+	if !ok {
+		for i, msg := range msgs {
+			outs[i], errs[i] = c.Print(msg)
+		}
+		return outs, errs
+	}
+	batch, err := safePrintBatch(bp, msgs)
+	for i, msg := range msgs {
+		switch {
+		case err != nil:
+			errs[i] = &LegacyError{Op: "print batch", Msg: msg, Err: err}
+		case i >= len(batch):
+			errs[i] = &LegacyError{Op: "print batch", Msg: msg,
+				Err: fmt.Errorf("batch printer returned %d outputs for %d messages", len(batch), len(msgs))}
+		default:
+			if errs[i] = c.check("print batch", msg, batch[i]); errs[i] == nil {
+				outs[i] = batch[i]
+			}
+		}
+	}
+	return outs, errs
+}
+
+// Unwrap returns the legacy printer
+func (c *CheckedPrinter) Unwrap() LegacyPrinter {
+	return c.legacyPrinter
+}
+
+// check translates empty output and sentinels into errors
+func (c *CheckedPrinter) check(op, msg, out string) error {
+	if strings.TrimSpace(out) == "" {
+		return &LegacyError{Op: op, Msg: msg, Output: out, Err: ErrEmptyOutput}
+	}
+	for _, s := range c.sentinels {
+		if strings.HasPrefix(out, s.Prefix) {
+			return &LegacyError{Op: op, Msg: msg, Output: out, Err: s.Err}
+		}
+	}
+	return nil
+}
+
+func safePrintBatch(bp BatchPrinter, msgs []string) (out []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return bp.PrintBatch(msgs), nil
+}
+
+// FallibleAdapter adapts FallibleLegacyPrinter to FallibleModernPrinter
+type FallibleAdapter struct {
+	legacyPrinter FallibleLegacyPrinter
+	msg           string
+}
+
+// NewFallibleAdapter creates an adapter that prints msg through the legacy printer
+func NewFallibleAdapter(legacyPrinter FallibleLegacyPrinter, msg string) *FallibleAdapter {
+	return &FallibleAdapter{legacyPrinter: legacyPrinter, msg: msg}
+}
+
+func (p *FallibleAdapter) PrintStored() (string, error) {
+	return p.legacyPrinter.Print(p.msg)
+}
@@ -0,0 +1,147 @@
+package adapter
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCheckedPrinter(t *testing.T) {
+	errJammed := errors.New("paper jam")
+	tests := []struct {
+		name      string
+		printer   LegacyPrinterFunc
+		sentinels []Sentinel
+		want      string
+		cause     error
+	}{
+		{"ok", func(s string) string { return "printed " + s }, nil, "printed hi", nil},
+		{"empty", func(s string) string { return "" }, nil, "", ErrEmptyOutput},
+		{"whitespace", func(s string) string { return " \n" }, nil, "", ErrEmptyOutput},
+		{"default sentinel", func(s string) string { return "ERROR: out of toner" }, nil, "", ErrLegacyFailure},
+		{"custom sentinel", func(s string) string { return "JAM 3" }, []Sentinel{{Prefix: "JAM", Err: errJammed}}, "", errJammed},
+		{"custom sentinels replace the default", func(s string) string { return "ERROR: fine" },
+			[]Sentinel{{Prefix: "JAM", Err: errJammed}}, "ERROR: fine", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := NewCheckedPrinter(tt.printer, tt.sentinels...).Print("hi")
+			if out != tt.want {
+				t.Errorf("output = %q, want %q", out, tt.want)
+			}
+			if tt.cause == nil {
+				if err != nil {
+					t.Errorf("err = %v", err)
+				}
+				return
+			}
+			var legacyErr *LegacyError
+			if !errors.As(err, &legacyErr) || legacyErr.Op != "print" || legacyErr.Msg != "hi" {
+				t.Fatalf("err = %v, want a *LegacyError for print %q", err, "hi")
+			}
+			if !errors.Is(err, tt.cause) {
+				t.Errorf("err = %v, want it to wrap %v", err, tt.cause)
+			}
+		})
+	}
+}
+
+func TestCheckedPrinterPanic(t *testing.T) {
+	errJammed := errors.New("paper jam")
+	for _, value := range []any{"out of paper", errJammed} {
+		_, err := NewCheckedPrinter(LegacyPrinterFunc(func(s string) string { panic(value) })).Print("hi")
+		var legacyErr *LegacyError
+		var panicErr *PanicError
+		if !errors.As(err, &legacyErr) || !errors.As(err, &panicErr) {
+			t.Fatalf("err = %v, want a *LegacyError wrapping a *PanicError", err)
+		}
+		if panicErr.Value != value || len(panicErr.Stack) == 0 {
+			t.Errorf("PanicError = %v with %d bytes of stack", panicErr.Value, len(panicErr.Stack))
+		}
+		// a panic with an error value can be matched through both wrappers
+		if _, isErr := value.(error); isErr != errors.Is(err, errJammed) {
+			t.Errorf("errors.Is(%v, errJammed) = %v", err, !isErr)
+		}
+	}
+}
+
+// batchPrinter is a BatchPrinter that returns the outputs it is given
+type batchPrinter struct {
+	outputs []string
+	panics  bool
+}
+
+func (b batchPrinter) Print(s string) string {
+	return s
+}
+
+func (b batchPrinter) PrintBatch(msgs []string) []string {
+	if b.panics {
+		panic("batch jam")
+	}
+	return b.outputs
+}
+
+func TestCheckedPrinterBatch(t *testing.T) {
+	msgs := []string{"a", "b", "c"}
+
+	outs, errs := NewCheckedPrinter(batchPrinter{outputs: []string{"A", "ERROR: b", "C"}}).PrintBatch(msgs)
+	if outs[0] != "A" || outs[1] != "" || outs[2] != "C" {
+		t.Errorf("outputs = %q", outs)
+	}
+	if errs[0] != nil || !errors.Is(errs[1], ErrLegacyFailure) || errs[2] != nil {
+		t.Errorf("errors = %v", errs)
+	}
+
+	// a short batch fails the messages without an output
+	outs, errs = NewCheckedPrinter(batchPrinter{outputs: []string{"A"}}).PrintBatch(msgs)
+	if outs[0] != "A" || errs[0] != nil {
+		t.Errorf("first message: %q, %v", outs[0], errs[0])
+	}
+	for _, err := range errs[1:] {
+		var legacyErr *LegacyError
+		if !errors.As(err, &legacyErr) || legacyErr.Op != "print batch" || !strings.Contains(err.Error(), "1 outputs for 3 messages") {
+			t.Errorf("err = %v, want a batch length mismatch", err)
+		}
+	}
+
+	// a panic fails the whole batch
+	_, errs = NewCheckedPrinter(batchPrinter{panics: true}).PrintBatch(msgs)
+	for i, err := range errs {
+		var panicErr *PanicError
+		if !errors.As(err, &panicErr) || panicErr.Value != "batch jam" {
+			t.Errorf("message %d: err = %v, want a *PanicError", i, err)
+		}
+	}
+
+	// printers without PrintBatch are called once per message
+	outs, errs = NewCheckedPrinter(NewMyLegacyPrinter(io.Discard)).PrintBatch(msgs)
+	if outs[2] != "Legacy Printer: c" || errs[2] != nil {
+		t.Errorf("fallback: %q, %v", outs, errs)
+	}
+}
+
+func TestBatchAdapterTranslatesFailures(t *testing.T) {
+	a := NewBatchAdapter(LegacyPrinterFunc(func(s string) string {
+		if s == "bad" {
+			return "ERROR: " + s
+		}
+		return s
+	}), BatchOptions{})
+	results, err := a.PrintAll(t.Context(), []string{"ok", "bad", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Err != nil || !errors.Is(results[1].Err, ErrLegacyFailure) || !errors.Is(results[2].Err, ErrEmptyOutput) {
+		t.Errorf("results = %+v", results)
+	}
+}
+
+func TestFallibleAdapter(t *testing.T) {
+	checked := NewCheckedPrinter(LegacyPrinterFunc(func(s string) string { return "" }))
+	var p FallibleModernPrinter = NewFallibleAdapter(checked, "hi")
+	if _, err := p.PrintStored(); !errors.Is(err, ErrEmptyOutput) {
+		t.Errorf("err = %v, want ErrEmptyOutput", err)
+	}
+}
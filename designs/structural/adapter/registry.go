@@ -0,0 +1,147 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// PrinterAdapter is written by hand for one pair of interfaces. A Registry generalises it: adapter constructors are
+// registered from a source type to a target type, and Resolve finds a way to turn any value into the target type. The
+// source can be an interface, so every type implementing LegacyPrinter is picked up without being registered itself,
+// and adapters are chained when there is no direct one (A to B, then B to C).
+
+// ErrNilValue is the cause when Resolve is given a nil pointer, map, func or channel, which no adapter can use
+var ErrNilValue = errors.New("adapter: nil value")
+
+// NoAdapterError is returned when no chain of adapters turns a value into the target type
+type NoAdapterError struct {
+	From, To reflect.Type
+	// Errs holds the errors returned by adapters that were tried and failed
+	Errs []error
+}
+
+func (e *NoAdapterError) Error() string {
+	msg := fmt.Sprintf("adapter: no adapter path from %v to %v", e.From, e.To)
+	if len(e.Errs) > 0 {
+		msg += ": " + errors.Join(e.Errs...).Error()
+	}
+	return msg
+}
+
+func (e *NoAdapterError) Unwrap() []error {
+	return e.Errs
+}
+
+type registration struct {
+	from, to reflect.Type
+	adapt    func(any) (any, error)
+}
+
+// Registry holds adapter constructors. It is safe for concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	adapters []registration
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds an adapter constructor from From to To. From may be an interface, in which case the adapter applies to
+// every value implementing it.
+func Register[From, To any](r *Registry, adapt func(From) (To, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.adapters = append(r.adapters, registration{
+		from: reflect.TypeFor[From](),
+		to:   reflect.TypeFor[To](),
+		adapt: func(v any) (any, error) {
+			return adapt(v.(From))
+		},
+	})
+}
+
+// accepts reports whether a value of type t can be passed to the adapter
+func (reg registration) accepts(t reflect.Type) bool {
+	if reg.from.Kind() == reflect.Interface {
+		return t.Implements(reg.from)
+	}
+	return t == reg.from
+}
+
+// isNil reports whether v is nil or a typed nil, such as a nil *MyLegacyPrinter in a LegacyPrinter
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Func, reflect.Chan, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// Resolve turns v into a To. A value that already is a To is returned as-is; otherwise the shortest chain of registered
+// adapters is used. When several chains are equally short, adapters registered earlier are tried first. A typed nil
+// is rejected with ErrNilValue rather than handed to an adapter, and an adapter that returns one is treated as having
+// returned nothing.
+func Resolve[To any](r *Registry, v any) (To, error) {
+	var zero To
+	target := reflect.TypeFor[To]()
+	if v == nil {
+		return zero, &NoAdapterError{To: target}
+	}
+	if isNil(v) {
+		return zero, &NoAdapterError{From: reflect.TypeOf(v), To: target, Errs: []error{ErrNilValue}}
+	}
+	if to, ok := v.(To); ok {
+		return to, nil
+	}
+
+	r.mu.RLock()
+	adapters := append([]registration(nil), r.adapters...)
+	r.mu.RUnlock()
+
+	// breadth first over types, so the shortest path wins. A type is expanded only the first time it is reached: any
+	// later path to it is at least as long, which also keeps cycles of adapters from looping.
+	visited := map[reflect.Type]bool{reflect.TypeOf(v): true}
+	queue := []any{v}
+	var errs []error
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, reg := range adapters {
+			if !reg.accepts(reflect.TypeOf(cur)) {
+				continue
+			}
+			out, err := reg.adapt(cur)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("adapter: %v to %v: %w", reg.from, reg.to, err))
+				continue
+			}
+			if isNil(out) {
+				continue
+			}
+			if to, ok := out.(To); ok {
+				return to, nil
+			}
+			if t := reflect.TypeOf(out); !visited[t] {
+				visited[t] = true
+				queue = append(queue, out)
+			}
+		}
+	}
+	return zero, &NoAdapterError{From: reflect.TypeOf(v), To: target, Errs: errs}
+}
+
+// RegisterPrinterAdapters registers the adapters between LegacyPrinter and ModernPrinter in both directions. Every
+// resolved ModernPrinter prints msg.
+func RegisterPrinterAdapters(r *Registry, msg string) {
+	Register(r, func(lp LegacyPrinter) (ModernPrinter, error) {
+		return NewPrinterAdapter(lp, msg), nil
+	})
+	Register(r, ToLegacy)
+}
@@ -0,0 +1,128 @@
+package adapter
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// the value of each test type records the adapters it went through
+type (
+	typeA string
+	typeB string
+	typeC string
+	typeD string
+	typeX string
+)
+
+func TestResolvePath(t *testing.T) {
+	r := NewRegistry()
+	Register(r, func(v typeA) (typeB, error) { return typeB(v + ">B"), nil })
+	Register(r, func(v typeB) (typeC, error) { return typeC(v + ">C"), nil })
+	Register(r, func(v typeC) (typeD, error) { return typeD(v + ">D"), nil })
+	// a cycle back to the start must not stop the search or loop forever
+	Register(r, func(v typeB) (typeA, error) { return typeA(v + ">A"), nil })
+
+	tests := []struct {
+		name string
+		got  func() (any, error)
+		want any
+	}{
+		{"already the target", func() (any, error) { return Resolve[typeA](r, typeA("a")) }, typeA("a")},
+		{"direct", func() (any, error) { return Resolve[typeB](r, typeA("a")) }, typeB("a>B")},
+		{"two steps", func() (any, error) { return Resolve[typeC](r, typeA("a")) }, typeC("a>B>C")},
+		{"three steps", func() (any, error) { return Resolve[typeD](r, typeA("a")) }, typeD("a>B>C>D")},
+		{"from the middle", func() (any, error) { return Resolve[typeD](r, typeB("b")) }, typeD("b>C>D")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.got()
+			if err != nil || got != tt.want {
+				t.Errorf("Resolve = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+
+	// a shortcut registered last still beats the longer chain
+	Register(r, func(v typeA) (typeX, error) { return typeX(v + ">X"), nil })
+	Register(r, func(v typeX) (typeD, error) { return typeD(v + ">D"), nil })
+	if got, err := Resolve[typeD](r, typeA("a")); err != nil || got != "a>X>D" {
+		t.Errorf("Resolve = %v, %v, want the two step path through X", got, err)
+	}
+}
+
+func TestResolveDuplicateRegistration(t *testing.T) {
+	errDown := errors.New("down")
+	r := NewRegistry()
+	Register(r, func(v typeA) (typeB, error) { return "first", nil })
+	Register(r, func(v typeA) (typeB, error) { return "second", nil })
+	if got, err := Resolve[typeB](r, typeA("a")); err != nil || got != "first" {
+		t.Errorf("Resolve = %v, %v, want the adapter registered first", got, err)
+	}
+
+	// when the first one fails the next one is tried
+	r = NewRegistry()
+	Register(r, func(v typeA) (typeB, error) { return "", errDown })
+	Register(r, func(v typeA) (typeB, error) { return "second", nil })
+	if got, err := Resolve[typeB](r, typeA("a")); err != nil || got != "second" {
+		t.Errorf("Resolve = %v, %v, want the second adapter", got, err)
+	}
+}
+
+func TestResolveNoPath(t *testing.T) {
+	errDown := errors.New("down")
+	r := NewRegistry()
+	Register(r, func(v typeA) (typeB, error) { return "", errDown })
+	Register(r, func(v typeA) (typeC, error) { return "c", nil })
+
+	_, err := Resolve[typeD](r, typeA("a"))
+	var noPath *NoAdapterError
+	if !errors.As(err, &noPath) {
+		t.Fatalf("err = %v, want a *NoAdapterError", err)
+	}
+	if noPath.From != reflect.TypeFor[typeA]() || noPath.To != reflect.TypeFor[typeD]() {
+		t.Errorf("NoAdapterError from %v to %v", noPath.From, noPath.To)
+	}
+	if !errors.Is(err, errDown) || len(noPath.Errs) != 1 {
+		t.Errorf("err = %v, want it to carry the failed adapter's error", err)
+	}
+
+	if _, err := Resolve[typeD](r, nil); !errors.As(err, &noPath) || noPath.From != nil {
+		t.Errorf("Resolve(nil) = %v", err)
+	}
+	if _, err := Resolve[typeD](NewRegistry(), typeA("a")); !errors.As(err, &noPath) || len(noPath.Errs) != 0 {
+		t.Errorf("empty registry: err = %v", err)
+	}
+}
+
+func TestResolvePrinters(t *testing.T) {
+	r := NewRegistry()
+	RegisterPrinterAdapters(r, "hello")
+	modern, err := Resolve[ModernPrinter](r, NewMyLegacyPrinter(io.Discard))
+	if err != nil || modern.PrintStored() != "Legacy Printer: hello" {
+		t.Fatalf("Resolve = %v, %v", modern, err)
+	}
+	legacy, err := Resolve[LegacyPrinter](r, modern)
+	if err != nil || legacy.Print("back") != "Legacy Printer: back" {
+		t.Errorf("Resolve back = %v, %v", legacy, err)
+	}
+
+	// a typed nil is not handed to an adapter, and is not returned as a LegacyPrinter either
+	for _, v := range []any{(*MyLegacyPrinter)(nil), LegacyPrinterFunc(nil), (*PrinterAdapter)(nil)} {
+		if _, err := Resolve[ModernPrinter](r, v); !errors.Is(err, ErrNilValue) {
+			t.Errorf("Resolve(%T(nil)) = %v, want ErrNilValue", v, err)
+		}
+		if _, err := Resolve[LegacyPrinter](r, v); !errors.Is(err, ErrNilValue) {
+			t.Errorf("Resolve(%T(nil)) = %v, want ErrNilValue", v, err)
+		}
+	}
+
+	// an adapter that returns a typed nil is a dead end
+	r = NewRegistry()
+	Register(r, func(v typeA) (LegacyPrinter, error) { return (*MyLegacyPrinter)(nil), nil })
+	Register(r, func(lp LegacyPrinter) (ModernPrinter, error) { return NewPrinterAdapter(lp, "x"), nil })
+	if _, err := Resolve[ModernPrinter](r, typeA("a")); err == nil {
+		t.Error("resolved through a nil printer")
+	}
+}
@@ -0,0 +1,52 @@
+package adapter
+
+import "errors"
+
+// During a migration both sides are live, so callers of the old API may need to use a ModernPrinter. LegacyAdapter
+// adapts in that direction.
+//
+// A ModernPrinter only prints the message it was built with, so LegacyAdapter holds a factory that builds a ModernPrinter
+// for each message. Neither adapter adds text of its own: the "Legacy Printer:" prefix comes only from MyLegacyPrinter,
+// so stacking adapters in either direction prints it exactly once.
+
+// ErrNotReversible is returned by ToLegacy for ModernPrinters that do not wrap a LegacyPrinter
+var ErrNotReversible = errors.New("adapter: ModernPrinter does not wrap a LegacyPrinter")
+
+// LegacyAdapter adapts ModernPrinter to LegacyPrinter
+type LegacyAdapter struct {
+	newPrinter func(msg string) ModernPrinter
+}
+
+// NewLegacyAdapter creates an adapter that prints each message through a ModernPrinter built by newPrinter
+func NewLegacyAdapter(newPrinter func(msg string) ModernPrinter) *LegacyAdapter {
+	return &LegacyAdapter{newPrinter: newPrinter}
+}
+
+func (a *LegacyAdapter) Print(s string) string {
+	return a.newPrinter(s).PrintStored()
+}
+
+// PrinterFactory returns a factory of PrinterAdapters around the legacy printer, for use with NewLegacyAdapter.
+// NewLegacyAdapter(PrinterFactory(lp)) prints exactly what lp prints.
+func PrinterFactory(lp LegacyPrinter) func(msg string) ModernPrinter {
+	return func(msg string) ModernPrinter {
+		return NewPrinterAdapter(lp, msg)
+	}
+}
+
+// Unwrap returns the legacy printer used by the adapter
+func (p *PrinterAdapter) Unwrap() LegacyPrinter {
+	if p.legacyPrinter == nil {
+		return NewMyLegacyPrinter(p.out)
+	}
+	return p.legacyPrinter
+}
+
+// ToLegacy returns the LegacyPrinter behind a ModernPrinter. Adapters are unwrapped rather than wrapped again, so the
+// result prints exactly what the original legacy printer prints.
+func ToLegacy(mp ModernPrinter) (LegacyPrinter, error) {
+	if w, ok := mp.(interface{ Unwrap() LegacyPrinter }); ok {
+		return w.Unwrap(), nil
+	}
+	return nil, ErrNotReversible
+}
@@ -0,0 +1,82 @@
+package adapter
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestLegacyAdapter(t *testing.T) {
+	var out bytes.Buffer
+	lp := NewMyLegacyPrinter(&out)
+	la := NewLegacyAdapter(PrinterFactory(lp))
+	if got := la.Print("hello"); got != "Legacy Printer: hello" {
+		t.Errorf("Print() = %q", got)
+	}
+	if out.String() != "Legacy Printer: hello\n" {
+		t.Errorf("wrote %q", out.String())
+	}
+}
+
+func TestToLegacy(t *testing.T) {
+	var out bytes.Buffer
+	lp := NewMyLegacyPrinter(&out)
+	back, err := ToLegacy(NewPrinterAdapter(lp, "ignored"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back != LegacyPrinter(lp) {
+		t.Errorf("ToLegacy returned %T, want the original printer", back)
+	}
+	if got := back.Print("hello"); got != "Legacy Printer: hello" {
+		t.Errorf("Print() = %q", got)
+	}
+
+	// the fallback printer of an adapter without one writes to its output
+	var fallback bytes.Buffer
+	back, err = ToLegacy(NewPrinterAdapter(nil, "ignored", WithOutput(&fallback)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := back.Print("hi"); got != "Legacy Printer: hi" || fallback.String() != "Legacy Printer: hi\n" {
+		t.Errorf("Print() = %q, wrote %q", got, fallback.String())
+	}
+
+	if _, err := ToLegacy(NewRemotePrinter("http://localhost", "hi")); !errors.Is(err, ErrNotReversible) {
+		t.Errorf("err = %v, want ErrNotReversible", err)
+	}
+}
+
+func TestNestedAdapters(t *testing.T) {
+	var out bytes.Buffer
+	lp := NewMyLegacyPrinter(&out)
+	const want = "Legacy Printer: stacked"
+
+	// legacy -> modern -> legacy -> modern
+	modern := NewPrinterAdapter(NewLegacyAdapter(PrinterFactory(lp)), "stacked")
+	if got := modern.PrintStored(); got != want {
+		t.Errorf("PrintStored() = %q, want %q", got, want)
+	}
+
+	// three round trips
+	var legacy LegacyPrinter = lp
+	for range 3 {
+		legacy = NewLegacyAdapter(PrinterFactory(legacy))
+	}
+	if got := legacy.Print("stacked"); got != want {
+		t.Errorf("Print() = %q, want %q", got, want)
+	}
+
+	// unwrapping a stack returns a printer with the same output
+	back, err := ToLegacy(modern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := back.Print("stacked"); got != want {
+		t.Errorf("ToLegacy(...).Print() = %q, want %q", got, want)
+	}
+
+	if out.String() != want+"\n"+want+"\n"+want+"\n" {
+		t.Errorf("wrote %q, want the message three times with one prefix each", out.String())
+	}
+}
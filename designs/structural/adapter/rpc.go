@@ -0,0 +1,237 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// Protocol adapters put a network between the client and the printer. PrinterHandler adapts ModernPrinter to
+// http.Handler, and RemotePrinter adapts the HTTP endpoint back to ModernPrinter, so a client cannot tell a remote
+// printer from a local one.
+//
+// The handler speaks JSON-RPC 2.0 over POST with a single method:
+//
+//	{"jsonrpc": "2.0", "id": 1, "method": "Printer.PrintStored", "params": {"msg": "hi"}}
+//	{"jsonrpc": "2.0", "id": 1, "result": "Legacy Printer: hi"}
+//
+// Printing has side effects, so other methods, GET included, are rejected. A printer that panics is reported to the
+// client as a bare internal error; the panic value and stack are logged on the server only.
+
+// PrintMethod is the JSON-RPC method served by PrinterHandler
+const PrintMethod = "Printer.PrintStored"
+
+// JSON-RPC 2.0 error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// RPCError is an error returned by the server
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("adapter: rpc error %d: %s", e.Code, e.Message)
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  *string         `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+type printParams struct {
+	Msg string `json:"msg"`
+}
+
+// PrinterHandler serves ModernPrinters over HTTP
+type PrinterHandler struct {
+	newPrinter func(msg string) ModernPrinter
+	logger     *slog.Logger
+}
+
+// HandlerOption configures a PrinterHandler
+type HandlerOption func(*PrinterHandler)
+
+// WithLogger sets the logger that records printer panics. The default is slog.Default().
+func WithLogger(logger *slog.Logger) HandlerOption {
+	return func(h *PrinterHandler) {
+		h.logger = logger
+	}
+}
+
+// NewPrinterHandler creates a handler that prints each requested message through a ModernPrinter built by newPrinter,
+// such as PrinterFactory(lp)
+func NewPrinterHandler(newPrinter func(msg string) ModernPrinter, opts ...HandlerOption) *PrinterHandler {
+	h := &PrinterHandler{newPrinter: newPrinter}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.logger == nil {
+		h.logger = slog.Default()
+	}
+	return h
+}
+
+func (h *PrinterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	h.serveRPC(w, r)
+}
+
+func (h *PrinterHandler) serveRPC(w http.ResponseWriter, r *http.Request) {
+	resp := rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null")}
+	var req rpcRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		resp.Error = &RPCError{Code: CodeParseError, Message: err.Error()}
+		writeRPC(w, resp)
+		return
+	}
+	if req.ID != nil {
+		resp.ID = req.ID
+	}
+	var params printParams
+	switch {
+	case req.JSONRPC != "2.0":
+		resp.Error = &RPCError{Code: CodeInvalidRequest, Message: `jsonrpc must be "2.0"`}
+	case req.Method != PrintMethod:
+		resp.Error = &RPCError{Code: CodeMethodNotFound, Message: fmt.Sprintf("method %q not found", req.Method)}
+	case json.Unmarshal(req.Params, &params) != nil:
+		resp.Error = &RPCError{Code: CodeInvalidParams, Message: `params must be {"msg": string}`}
+	default:
+		out, err := h.print(params.Msg)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "printer panicked", "id", string(resp.ID), "panic", err.Value,
+				"stack", string(err.Stack))
+			resp.Error = &RPCError{Code: CodeInternalError, Message: "internal error"}
+		} else {
+			resp.Result = &out
+		}
+	}
+	if req.ID == nil {
+		// a notification gets no response
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeRPC(w, resp)
+}
+
+// print recovers a panicking printer, which is how a failing ModernPrinter reports an error
+func (h *PrinterHandler) print(msg string) (out string, err *PanicError) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return h.newPrinter(msg).PrintStored(), nil
+}
+
+func writeRPC(w http.ResponseWriter, resp rpcResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// RemotePrinter adapts a PrinterHandler endpoint to ModernPrinter
+type RemotePrinter struct {
+	url    string
+	msg    string
+	client *http.Client
+	nextID atomic.Int64
+}
+
+// RemoteOption configures a RemotePrinter
+type RemoteOption func(*RemotePrinter)
+
+// WithHTTPClient sets the client used for requests. Defaults to http.DefaultClient.
+func WithHTTPClient(client *http.Client) RemoteOption {
+	return func(p *RemotePrinter) {
+		p.client = client
+	}
+}
+
+// NewRemotePrinter creates a printer that prints msg through the endpoint at url
+func NewRemotePrinter(url, msg string, opts ...RemoteOption) *RemotePrinter {
+	p := &RemotePrinter{url: url, msg: msg, client: http.DefaultClient}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// PrintStored prints the message remotely. ModernPrinter cannot report failure, so it returns "" when the call fails;
+// use PrintStoredContext to get the error.
+func (p *RemotePrinter) PrintStored() string {
+	out, _ := p.PrintStoredContext(context.Background())
+	return out
+}
+
+// PrintStoredContext prints the message remotely. Errors reported by the server are *RPCError.
+func (p *RemotePrinter) PrintStoredContext(ctx context.Context) (string, error) {
+	id := p.nextID.Add(1)
+	body, err := json.Marshal(rpcRequest{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage(fmt.Sprint(id)),
+		Method:  PrintMethod,
+		Params:  mustMarshal(printParams{Msg: p.msg}),
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("adapter: rpc: unexpected status %s", res.Status)
+	}
+	var resp rpcResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return "", fmt.Errorf("adapter: rpc: decoding response: %w", err)
+	}
+	if resp.Error != nil {
+		return "", resp.Error
+	}
+	if string(resp.ID) != fmt.Sprint(id) {
+		return "", fmt.Errorf("adapter: rpc: response id %s does not match request id %d", resp.ID, id)
+	}
+	if resp.Result == nil {
+		return "", fmt.Errorf("adapter: rpc: response has neither result nor error")
+	}
+	return *resp.Result, nil
+}
+
+func mustMarshal(v any) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,150 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func newRPCServer(t *testing.T, lp LegacyPrinter) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(NewPrinterHandler(PrinterFactory(lp)))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func postRPC(t *testing.T, url, body string) (*http.Response, rpcResponse) {
+	t.Helper()
+	res, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var resp rpcResponse
+	if res.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return res, resp
+}
+
+func TestRemotePrinter(t *testing.T) {
+	server := newRPCServer(t, NewMyLegacyPrinter(io.Discard))
+	remote := NewRemotePrinter(server.URL, "Over the wire", WithHTTPClient(server.Client()))
+	for range 2 {
+		out, err := remote.PrintStoredContext(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != "Legacy Printer: Over the wire" {
+			t.Errorf("PrintStoredContext() = %q", out)
+		}
+	}
+	if got := remote.PrintStored(); got != "Legacy Printer: Over the wire" {
+		t.Errorf("PrintStored() = %q", got)
+	}
+}
+
+func TestPrinterHandlerNotification(t *testing.T) {
+	var printed atomic.Int32
+	server := newRPCServer(t, LegacyPrinterFunc(func(s string) string {
+		printed.Add(1)
+		return s
+	}))
+	res, _ := postRPC(t, server.URL, `{"jsonrpc":"2.0","method":"Printer.PrintStored","params":{"msg":"hi"}}`)
+	if res.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %d, want 204", res.StatusCode)
+	}
+	if printed.Load() != 1 {
+		t.Errorf("printed %d times, want 1", printed.Load())
+	}
+}
+
+func TestPrinterHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"malformed", `{"jsonrpc":`, CodeParseError},
+		{"bad version", `{"jsonrpc":"1.0","id":1,"method":"Printer.PrintStored","params":{"msg":"hi"}}`, CodeInvalidRequest},
+		{"unknown method", `{"jsonrpc":"2.0","id":1,"method":"Printer.Shred","params":{"msg":"hi"}}`, CodeMethodNotFound},
+		{"bad params", `{"jsonrpc":"2.0","id":1,"method":"Printer.PrintStored","params":["hi"]}`, CodeInvalidParams},
+		{"missing params", `{"jsonrpc":"2.0","id":1,"method":"Printer.PrintStored"}`, CodeInvalidParams},
+	}
+	server := newRPCServer(t, NewMyLegacyPrinter(io.Discard))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, resp := postRPC(t, server.URL, tt.body)
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want 200", res.StatusCode)
+			}
+			if resp.Error == nil || resp.Error.Code != tt.code || resp.Result != nil {
+				t.Errorf("response = %+v, want error code %d", resp, tt.code)
+			}
+		})
+	}
+}
+
+func TestPrinterHandlerPanickingPrinter(t *testing.T) {
+	var logs bytes.Buffer
+	handler := NewPrinterHandler(PrinterFactory(LegacyPrinterFunc(func(s string) string { panic("paper jam") })),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	server := httptest.NewServer(handler)
+
+	_, err := NewRemotePrinter(server.URL, "hi", WithHTTPClient(server.Client())).PrintStoredContext(context.Background())
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeInternalError || rpcErr.Message != "internal error" {
+		t.Errorf("err = %v, want a bare internal error", err)
+	}
+	// the details stay on the server; Close waits for the handler to finish logging
+	server.Close()
+	log := logs.String()
+	for _, want := range []string{"level=ERROR", `msg="printer panicked"`, `panic="paper jam"`, "stack=", "rpc.go"} {
+		if !strings.Contains(log, want) {
+			t.Errorf("log %q does not contain %q", log, want)
+		}
+	}
+}
+
+func TestPrinterHandlerRejectsGet(t *testing.T) {
+	var printed atomic.Int32
+	server := newRPCServer(t, LegacyPrinterFunc(func(s string) string {
+		printed.Add(1)
+		return s
+	}))
+	res, err := http.Get(server.URL + "?msg=hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusMethodNotAllowed || res.Header.Get("Allow") != http.MethodPost {
+		t.Errorf("status = %d, Allow = %q", res.StatusCode, res.Header.Get("Allow"))
+	}
+	if printed.Load() != 0 {
+		t.Error("GET printed a message")
+	}
+}
+
+func TestRemotePrinterIDMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"jsonrpc":"2.0","id":99,"result":"someone else's"}`)
+	}))
+	defer server.Close()
+	remote := NewRemotePrinter(server.URL, "hi", WithHTTPClient(server.Client()))
+	if _, err := remote.PrintStoredContext(context.Background()); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("err = %v, want an ID mismatch", err)
+	}
+	if got := remote.PrintStored(); got != "" {
+		t.Errorf("PrintStored() = %q, want \"\" on failure", got)
+	}
+}
@@ -0,0 +1,126 @@
+package adapter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestBuffer(t *testing.T) {
+	var b Buffer
+	if b.Lines() != nil {
+		t.Errorf("empty buffer has lines %q", b.Lines())
+	}
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			NewMyLegacyPrinter(&b).Print("x")
+		}()
+	}
+	wg.Wait()
+	lines := b.Lines()
+	if len(lines) != 100 || slices.ContainsFunc(lines, func(l string) bool { return l != "Legacy Printer: x" }) {
+		t.Errorf("got %d lines, some interleaved: %q", len(lines), lines[:min(len(lines), 3)])
+	}
+
+	b.Reset()
+	b.Write([]byte("one\n\nthree"))
+	if got := b.Lines(); !slices.Equal(got, []string{"one", "", "three"}) {
+		t.Errorf("Lines() = %q", got)
+	}
+}
+
+func TestMultiSink(t *testing.T) {
+	var a, b Buffer
+	NewMyLegacyPrinter(MultiSink(&a, &b)).Print("both")
+	if a.String() != "Legacy Printer: both\n" || a.String() != b.String() {
+		t.Errorf("sinks got %q and %q", a.String(), b.String())
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "printer.log")
+	r, err := NewRotatingFile(path, 12, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// each write is 6 bytes, so every second write rotates
+	for _, s := range []string{"aaaaa\n", "bbbbb\n", "ccccc\n", "ddddd\n", "eeeee\n"} {
+		if _, err := r.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := map[string]string{path: "eeeee\n", path + ".1": "ccccc\nddddd\n", path + ".2": "aaaaa\nbbbbb\n"}
+	for p, content := range want {
+		if got := readFile(t, p); got != content {
+			t.Errorf("%s = %q, want %q", filepath.Base(p), got, content)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("a third backup was kept: %v", err)
+	}
+
+	// a write larger than the limit goes into a file of its own
+	r.Write([]byte("a very long line\n"))
+	if got := readFile(t, path); got != "a very long line\n" {
+		t.Errorf("current file = %q", got)
+	}
+	if got := readFile(t, path+".1"); got != "eeeee\n" {
+		t.Errorf("newest backup = %q", got)
+	}
+}
+
+func TestRotatingFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "printer.log")
+	os.WriteFile(path, []byte("old\n"), 0o644)
+	r, err := NewRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Write([]byte("new\n"))
+	r.Write([]byte("newer\n"))
+	r.Close()
+	if readFile(t, path+".1") != "old\nnew\n" || readFile(t, path) != "newer\n" {
+		t.Errorf("existing size was not counted: %q, %q", readFile(t, path+".1"), readFile(t, path))
+	}
+	if _, err := r.Write([]byte("x")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("write after Close: err = %v", err)
+	}
+}
+
+func TestRotatingFileNoBackups(t *testing.T) {
+	for _, maxBackups := range []int{0, -1} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "printer.log")
+		r, err := NewRotatingFile(path, 8, maxBackups)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Write([]byte("first\n"))
+		r.Write([]byte("second\n"))
+		r.Close()
+		if got := readFile(t, path); got != "second\n" {
+			t.Errorf("maxBackups %d: file = %q", maxBackups, got)
+		}
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 1 {
+			t.Errorf("maxBackups %d: %d files left, want 1", maxBackups, len(entries))
+		}
+	}
+}
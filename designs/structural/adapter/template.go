@@ -0,0 +1,50 @@
+package adapter
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// TemplateAdapter renders its message as a text/template with structured data before it is handed to the
+// LegacyPrinter, so callers can print
+//
+//	p, err := NewTemplateAdapter(lp, "Invoice {{.ID}} due {{.Date}}", invoice)
+//	out, err := p.PrintStored()
+//
+// without formatting the message themselves. Rendering can fail, so it is a FallibleModernPrinter and every call returns
+// its own error. The template is parsed once, when the adapter is created, and belongs to that adapter. A missing map
+// key is an error rather than "<no value>".
+
+// TemplateAdapter adapts LegacyPrinter to FallibleModernPrinter through a template. It is safe for concurrent use if
+// the legacy printer is.
+type TemplateAdapter struct {
+	printer *PrinterAdapter
+	tmpl    *template.Template
+	data    any
+}
+
+// NewTemplateAdapter parses text and creates an adapter that prints it rendered with data. A nil legacy printer falls
+// back to MyLegacyPrinter, which writes to the WithOutput writer.
+func NewTemplateAdapter(legacyPrinter LegacyPrinter, text string, data any, opts ...AdapterOption) (*TemplateAdapter, error) {
+	tmpl, err := template.New("msg").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("adapter: parsing template: %w", err)
+	}
+	return &TemplateAdapter{printer: NewPrinterAdapter(legacyPrinter, text, opts...), tmpl: tmpl, data: data}, nil
+}
+
+// PrintStored renders the template with the adapter's data and prints the result. Nothing is printed if rendering
+// fails.
+func (t *TemplateAdapter) PrintStored() (string, error) {
+	return t.PrintWith(t.data)
+}
+
+// PrintWith renders the template with data and prints the result. Nothing is printed if rendering fails.
+func (t *TemplateAdapter) PrintWith(data any) (string, error) {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("adapter: rendering template: %w", err)
+	}
+	return t.printer.print(b.String()), nil
+}
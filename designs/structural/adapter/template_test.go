@@ -0,0 +1,75 @@
+package adapter
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTemplateAdapter(t *testing.T) {
+	invoice := struct {
+		ID   int
+		Date string
+	}{ID: 42, Date: "2024-07-01"}
+	var out Buffer
+	p, err := NewTemplateAdapter(nil, "Invoice {{.ID}} due {{.Date}}", invoice, WithOutput(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var _ FallibleModernPrinter = p
+
+	got, err := p.PrintStored()
+	if err != nil || got != "Legacy Printer: Invoice 42 due 2024-07-01" {
+		t.Errorf("PrintStored() = %q, %v", got, err)
+	}
+	got, err = p.PrintWith(map[string]any{"ID": 7, "Date": "tomorrow"})
+	if err != nil || got != "Legacy Printer: Invoice 7 due tomorrow" {
+		t.Errorf("PrintWith() = %q, %v", got, err)
+	}
+
+	// a missing key fails the call that hit it, prints nothing and leaves the next call alone
+	out.Reset()
+	if got, err := p.PrintWith(map[string]any{"ID": 7}); err == nil || got != "" || !strings.Contains(err.Error(), "Date") {
+		t.Errorf("PrintWith without Date = %q, %v", got, err)
+	}
+	if out.Lines() != nil {
+		t.Errorf("a failed render printed %q", out.Lines())
+	}
+	if _, err := p.PrintStored(); err != nil {
+		t.Errorf("PrintStored() after a failure = %v", err)
+	}
+}
+
+func TestTemplateAdapterParseError(t *testing.T) {
+	p, err := NewTemplateAdapter(nil, "Invoice {{.ID", nil)
+	if p != nil || err == nil || !strings.HasPrefix(err.Error(), "adapter: parsing template") {
+		t.Errorf("NewTemplateAdapter = %v, %v", p, err)
+	}
+}
+
+func TestTemplateAdapterConcurrent(t *testing.T) {
+	var out Buffer
+	p, err := NewTemplateAdapter(nil, "{{.Name}}", map[string]string{"Name": "x"}, WithOutput(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// half the calls fail, and none of them affects another
+			if i%2 == 0 {
+				if _, err := p.PrintStored(); err != nil {
+					t.Errorf("PrintStored() = %v", err)
+				}
+			} else if _, err := p.PrintWith(map[string]string{}); err == nil {
+				t.Error("PrintWith() without Name succeeded")
+			}
+		}()
+	}
+	wg.Wait()
+	if len(out.Lines()) != 25 {
+		t.Errorf("printed %d lines, want 25", len(out.Lines()))
+	}
+}
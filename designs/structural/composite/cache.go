@@ -0,0 +1,55 @@
+package composite
+
+// Bundles cache their price. Every change to a bundle, and every change to a product through its setters, clears the
+// cache of the bundle it belongs to and of all bundles above it, so a price query after a change only recomputes the
+// bundles on the path to the root instead of the whole tree.
+//
+// A cached bundle only has cached bundles below it, so clearing can stop at the first bundle that is already cleared.
+// Products other than SingleProduct and ProductBundle cannot report their changes; a bundle holding one that changes
+// price needs GetPriceUncached, which never touches the cache.
+//
+// Concurrent GetPrice calls may compute the same bundle twice, but always store the same price. Changes to the tree
+// must not run concurrently with anything else, as before caching.
+
+// invalidate clears the cached price of b and of the bundles above it
+func (b *ProductBundle) invalidate() {
+	for ; b != nil && b.priced.Load(); b = b.parent {
+		b.priced.Store(false)
+	}
+}
+
+// computePrice sums the children, priced with price, and applies the bundle's policies
+func (b *ProductBundle) computePrice(price func(Product) float64) float64 {
+	total := 0.0
+	prices := make([]float64, 0, len(b.products))
+	for _, child := range b.products {
+		p := price(child)
+		prices = appendItems(prices, child, p)
+		total += p
+	}
+	return b.applyPolicies(prices, total, nil)
+}
+
+// GetPriceUncached computes the price of the whole subtree without using or filling the cache
+func (b *ProductBundle) GetPriceUncached() float64 {
+	return b.computePrice(uncachedPrice)
+}
+
+func uncachedPrice(p Product) float64 {
+	if b, ok := p.(*ProductBundle); ok {
+		return b.GetPriceUncached()
+	}
+	return p.GetPrice()
+}
+
+// SetPrice changes the unit price
+func (p *SingleProduct) SetPrice(price float64) {
+	p.price = price
+	p.parent.invalidate()
+}
+
+// SetQuantity changes the quantity. Values below 1 mean 1.
+func (p *SingleProduct) SetQuantity(quantity int) {
+	p.quantity = quantity
+	p.parent.invalidate()
+}
@@ -2,6 +2,8 @@ package composite
 
 import (
 	"fmt"
+	"math"
+	"sync/atomic"
 )
 
 // Component Interface: This interface defines the common operations that both leaf and composite objects must implement. In our example, the Product interface defines the GetPrice method.
@@ -19,28 +21,67 @@ type Product interface {
 
 // Leaf
 type SingleProduct struct {
-	price float64
+	id         string
+	price      float64
+	parent     *ProductBundle
+	name       string
+	quantity   int
+	weight     float64
+	dimensions Dimensions
+	taxClass   TaxClass
 }
 
+// NewSingleProduct creates a product with an ID, its SKU, and a unit price
+func NewSingleProduct(id string, price float64, opts ...ProductOption) *SingleProduct {
+	p := &SingleProduct{id: id, price: price}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// GetPrice returns the unit price times the quantity
 func (p *SingleProduct) GetPrice() float64 {
-	return p.price
+	return p.price * float64(p.Quantity())
 }
 
 // Composite
 type ProductBundle struct {
+	id       string
 	products []Product
+	parent   *ProductBundle
+	policies []PricingPolicy
+	priced   atomic.Bool
+	price    atomic.Uint64 // float64 bits
+}
+
+// NewProductBundle creates an empty bundle with an ID
+func NewProductBundle(id string) *ProductBundle {
+	return &ProductBundle{id: id}
 }
 
-func (b *ProductBundle) AddProduct(p Product) {
+// AddProduct appends p to the bundle. It fails with a *CycleError if p is the bundle or contains it, and with
+// ErrHasParent if p already belongs to a bundle; use Move to reparent it.
+func (b *ProductBundle) AddProduct(p Product) error {
+	if err := b.checkInsert(p); err != nil {
+		return err
+	}
 	b.products = append(b.products, p)
+	setParent(p, b)
+	b.invalidate()
+	return nil
 }
 
+// GetPrice returns the bundle's price. It is cached until the bundle or something below it changes. The cache is
+// atomic, so GetPrice can be called from many goroutines at once; changing the tree still needs exclusive access.
 func (b *ProductBundle) GetPrice() float64 {
-	total := 0.0
-	for _, p := range b.products {
-		total += p.GetPrice()
+	if b.priced.Load() {
+		return math.Float64frombits(b.price.Load())
 	}
-	return total
+	price := b.computePrice(Product.GetPrice)
+	b.price.Store(math.Float64bits(price))
+	b.priced.Store(true)
+	return price
 }
 
 func main() {
@@ -54,4 +95,86 @@ func main() {
 	bundle.AddProduct(product2)
 
 	fmt.Printf("Total price of the bundle: $%.2f\n", bundle.GetPrice()) // Output: Total price of the bundle: $70.00
+
+	// Walk a nested catalog
+	catalog := &ProductBundle{}
+	catalog.AddProduct(&SingleProduct{price: 10.0})
+	catalog.AddProduct(bundle)
+	for path, p := range DepthFirst(catalog) {
+		fmt.Printf("%s $%.2f\n", path, p.GetPrice())
+	}
+	// Output:
+	// root $80.00
+	// root → item[0] $10.00
+	// root → bundle[1] $70.00
+	// root → bundle[1] → item[0] $25.00
+	// root → bundle[1] → item[1] $45.00
+
+	if path, ok := Find(catalog, func(p Product) bool { return p.GetPrice() == 45.0 }); ok {
+		fmt.Println(path) // Output: root → bundle[1] → item[1]
+	}
+	fmt.Print(RenderTree(catalog))
+	// Output:
+	// bundle $80.00
+	//   item $10.00
+	//   bundle $70.00
+	//     item $25.00
+	//     item $45.00
+
+	// Restructure a catalog with IDs
+	gifts := NewProductBundle("gifts")
+	mugs := NewProductBundle("mugs")
+	gifts.AddProduct(mugs)
+	mugs.AddProduct(NewSingleProduct("mug-red", 12.0))
+	gifts.AddProduct(NewSingleProduct("card", 3.0))
+	fmt.Println(mugs.AddProduct(gifts)) // Output: composite: adding "gifts" to "mugs" would create a cycle
+	card, _ := gifts.RemoveByID("card")
+	mugs.Move(card)
+	path, _ := gifts.FindByID("card")
+	fmt.Println(path, card.(*SingleProduct).Parent().ID()) // Output: root → bundle[0] → item[1] mugs
+
+	// Attach pricing policies: 3 for 2 on mugs, then 10% off the whole gift bundle
+	mugs.AddProduct(NewSingleProduct("mug-blue", 10.0))
+	mugs.AddPolicy(BuyNGetM(2, 1))
+	gifts.AddPolicy(PercentOff(10))
+	fmt.Printf("$%.2f\n", gifts.GetPrice()) // Output: $19.80
+	fmt.Print(Explain(gifts))
+	// Output:
+	// "gifts" subtotal 22.00
+	//   "mugs" subtotal 25.00
+	//     "mug-red" 12.00
+	//     "card" 3.00
+	//     "mug-blue" 10.00
+	//     buy 2 get 1 free: -3.00 → 22.00
+	//   = 22.00
+	//   10% off: -2.20 → 19.80
+	// = 19.80
+
+	// Aggregate quantities, weights and taxes over a basket
+	basket := NewProductBundle("basket")
+	basket.AddProduct(NewSingleProduct("TEA-01", 4.0, WithName("Green tea"), WithQuantity(3), WithWeight(0.1), WithTaxClass(TaxReduced)))
+	basket.AddProduct(NewSingleProduct("KET-01", 30.0, WithName("Kettle"), WithWeight(1.2), WithDimensions(Dimensions{Length: 20, Width: 15, Height: 25})))
+	basket.AddPolicy(PercentOff(50))
+	fmt.Printf("%d items, %.1f kg, $%.2f\n", basket.ItemCount(), basket.TotalWeight(), basket.GetPrice()) // Output: 4 items, 1.5 kg, $21.00
+	tax := basket.TaxByClass(map[TaxClass]float64{TaxStandard: 0.2, TaxReduced: 0.05})
+	fmt.Printf("standard $%.2f, reduced $%.2f\n", tax[TaxStandard], tax[TaxReduced]) // Output: standard $3.00, reduced $0.30
+
+	// Save the basket as CSV and load it back
+	data, _ := EncodeTree(basket, FormatCSV)
+	fmt.Print(string(data))
+	// Output:
+	// parent,type,id,name,price,quantity,weight,length,width,height,tax_class,policies
+	// ,bundle,basket,,,,,,,,,"[{""kind"":""percent-off"",""percent"":50}]"
+	// basket,product,TEA-01,Green tea,4,3,0.1,,,,reduced,
+	// basket,product,KET-01,Kettle,30,,1.2,20,15,25,,
+	if loaded, err := DecodeTree(data, FormatCSV); err == nil {
+		fmt.Printf("$%.2f\n", loaded.GetPrice()) // Output: $21.00
+	}
+	_, err := DecodeTree([]byte("type: bundle\nproducts:\n  - type: product\n    price: free\n"), FormatYAML)
+	fmt.Println(err) // Output: composite: line 4, column 12: field "price": invalid value "free"
+
+	// Prices are cached, and a change only clears the bundles above it
+	fmt.Printf("$%.2f\n", basket.GetPrice()) // Output: $21.00 (cached)
+	basket.Products()[1].(*SingleProduct).SetQuantity(2)
+	fmt.Printf("$%.2f\n", basket.GetPrice()) // Output: $36.00
 }
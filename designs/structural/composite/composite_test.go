@@ -0,0 +1,160 @@
+package composite
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"testing"
+)
+
+func TestGetPriceMatchesUncachedAfterRandomChanges(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	root := NewProductBundle("root")
+	bundles := []*ProductBundle{root}
+	var products []*SingleProduct
+	policies := []func() PricingPolicy{
+		func() PricingPolicy { return PercentOff(10) },
+		func() PricingPolicy { return BuyNGetM(2, 1) },
+		func() PricingPolicy { return CheapestFree(3) },
+		func() PricingPolicy { return CappedPrice(50) },
+	}
+
+	for i := range 2000 {
+		switch op := rng.IntN(6); {
+		case op == 0 || len(products) == 0:
+			p := NewSingleProduct(fmt.Sprint("p", i), float64(rng.IntN(2000))/100, WithQuantity(rng.IntN(4)))
+			if err := bundles[rng.IntN(len(bundles))].AddProduct(p); err != nil {
+				t.Fatal(err)
+			}
+			products = append(products, p)
+		case op == 1:
+			b := NewProductBundle(fmt.Sprint("b", i))
+			if err := bundles[rng.IntN(len(bundles))].AddProduct(b); err != nil {
+				t.Fatal(err)
+			}
+			bundles = append(bundles, b)
+		case op == 2:
+			// moving a bundle into its own subtree must fail without changing anything
+			b := bundles[rng.IntN(len(bundles))]
+			if b == root {
+				continue
+			}
+			err := bundles[rng.IntN(len(bundles))].Move(b)
+			var cycle *CycleError
+			if err != nil && !errors.As(err, &cycle) {
+				t.Fatal(err)
+			}
+		case op == 3:
+			if err := bundles[rng.IntN(len(bundles))].Move(products[rng.IntN(len(products))]); err != nil {
+				t.Fatal(err)
+			}
+		case op == 4:
+			old := products[rng.IntN(len(products))]
+			parent := old.Parent()
+			if parent == nil {
+				continue
+			}
+			p := NewSingleProduct(fmt.Sprint("r", i), float64(rng.IntN(2000))/100)
+			if err := parent.Replace(old, p); err != nil {
+				t.Fatal(err)
+			}
+			products = append(products, p)
+		default:
+			if rng.IntN(2) == 0 {
+				products[rng.IntN(len(products))].SetQuantity(rng.IntN(5))
+			} else {
+				bundles[rng.IntN(len(bundles))].AddPolicy(policies[rng.IntN(len(policies))]())
+			}
+		}
+		if got, want := root.GetPrice(), root.GetPriceUncached(); math.Abs(got-want) > 1e-9 {
+			t.Fatalf("after step %d: GetPrice = %v, GetPriceUncached = %v", i, got, want)
+		}
+	}
+}
+
+func TestGetPriceConcurrentReads(t *testing.T) {
+	root := buildDeep(3)
+	want := root.GetPriceUncached()
+	var wg sync.WaitGroup
+	for range 64 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := root.GetPrice(); got != want {
+				t.Errorf("GetPrice = %v, want %v", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// buildWide returns a bundle of 100,000 products
+func buildWide() *ProductBundle {
+	wide := NewProductBundle("wide")
+	for i := range 100_000 {
+		wide.AddProduct(NewSingleProduct(fmt.Sprint(i), 1.0))
+	}
+	return wide
+}
+
+// buildDeep returns a tree with a fan-out of 10 and 10^(depth+1) products
+func buildDeep(depth int) *ProductBundle {
+	var grow func(b *ProductBundle, depth int)
+	grow = func(b *ProductBundle, depth int) {
+		for i := range 10 {
+			if depth == 0 {
+				b.AddProduct(NewSingleProduct(fmt.Sprint(b.ID(), "/", i), 1.0))
+				continue
+			}
+			child := NewProductBundle(fmt.Sprint(b.ID(), "/", i))
+			b.AddProduct(child)
+			grow(child, depth-1)
+		}
+	}
+	deep := NewProductBundle("deep")
+	grow(deep, depth)
+	return deep
+}
+
+func lastProduct(root *ProductBundle) *SingleProduct {
+	var leaf *SingleProduct
+	for _, p := range DepthFirst(root) {
+		if p, ok := p.(*SingleProduct); ok {
+			leaf = p
+		}
+	}
+	return leaf
+}
+
+func benchmarkCached(b *testing.B, root *ProductBundle) {
+	root.GetPrice()
+	b.ResetTimer()
+	for range b.N {
+		root.GetPrice()
+	}
+}
+
+func benchmarkUncached(b *testing.B, root *ProductBundle) {
+	for range b.N {
+		root.GetPriceUncached()
+	}
+}
+
+func benchmarkAfterChange(b *testing.B, root *ProductBundle) {
+	leaf := lastProduct(root)
+	root.GetPrice()
+	b.ResetTimer()
+	for i := range b.N {
+		leaf.SetQuantity(i%3 + 1)
+		root.GetPrice()
+	}
+}
+
+func BenchmarkGetPriceCachedWide(b *testing.B)      { benchmarkCached(b, buildWide()) }
+func BenchmarkGetPriceCachedDeep(b *testing.B)      { benchmarkCached(b, buildDeep(4)) }
+func BenchmarkGetPriceUncachedWide(b *testing.B)    { benchmarkUncached(b, buildWide()) }
+func BenchmarkGetPriceUncachedDeep(b *testing.B)    { benchmarkUncached(b, buildDeep(4)) }
+func BenchmarkGetPriceAfterChangeWide(b *testing.B) { benchmarkAfterChange(b, buildWide()) }
+func BenchmarkGetPriceAfterChangeDeep(b *testing.B) { benchmarkAfterChange(b, buildDeep(4)) }
@@ -0,0 +1,671 @@
+package composite
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Product trees are stored as nested JSON or YAML, or as flat CSV. In JSON and YAML every product is an object:
+//
+//	type: bundle
+//	id: gifts
+//	policies:
+//	  - kind: percent-off
+//	    percent: 10
+//	products:
+//	  - type: product
+//	    id: TEA-01
+//	    name: Green tea
+//	    price: 4
+//	    quantity: 3
+//	    tax_class: reduced
+//
+// CSV has one row per product, depth first, and refers to the parent bundle by the path of IDs from the root:
+//
+//	parent,type,id,name,price,quantity,weight,length,width,height,tax_class,policies
+//	,bundle,gifts,,,,,,,,,"[{""kind"":""percent-off"",""percent"":10}]"
+//	gifts,product,TEA-01,Green tea,4,3,,,,,reduced,
+//
+// so in CSV every bundle needs an ID that is unique among its siblings and does not contain "/".
+//
+// Only built-in pricing policies can be encoded. Malformed input is reported as a *ParseError with its position.
+
+// Format is the encoding of a product tree
+type Format int
+
+const (
+	FormatJSON Format = iota
+	FormatYAML
+	FormatCSV
+)
+
+// ParseError is malformed input, with the line and column where it was found
+type ParseError struct {
+	Line, Column int
+	// Field is the name of the offending field, if known
+	Field string
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("composite: line %d, column %d: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("composite: line %d, column %d: field %q: %v", e.Line, e.Column, e.Field, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// productNode is the nested JSON and YAML form of a product
+type productNode struct {
+	Type       string        `json:"type" yaml:"type"`
+	ID         string        `json:"id,omitempty" yaml:"id,omitempty"`
+	Name       string        `json:"name,omitempty" yaml:"name,omitempty"`
+	Price      float64       `json:"price,omitempty" yaml:"price,omitempty"`
+	Quantity   int           `json:"quantity,omitempty" yaml:"quantity,omitempty"`
+	Weight     float64       `json:"weight,omitempty" yaml:"weight,omitempty"`
+	Dimensions *Dimensions   `json:"dimensions,omitempty" yaml:"dimensions,omitempty"`
+	TaxClass   TaxClass      `json:"tax_class,omitempty" yaml:"tax_class,omitempty"`
+	Policies   []policySpec  `json:"policies,omitempty" yaml:"policies,omitempty"`
+	Products   []productNode `json:"products,omitempty" yaml:"products,omitempty"`
+}
+
+const (
+	typeBundle  = "bundle"
+	typeProduct = "product"
+)
+
+// LoadTree reads a product tree file. The format is picked from the extension: .json, .yaml, .yml or .csv.
+func LoadTree(path string) (*ProductBundle, error) {
+	var format Format
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		format = FormatJSON
+	case ".yaml", ".yml":
+		format = FormatYAML
+	case ".csv":
+		format = FormatCSV
+	default:
+		return nil, fmt.Errorf("composite: unsupported tree file %q", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeTree(data, format)
+}
+
+// EncodeTree encodes the tree in the given format
+func EncodeTree(root *ProductBundle, format Format) ([]byte, error) {
+	if format == FormatCSV {
+		return encodeCSV(root)
+	}
+	node, err := toNode(root)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatJSON:
+		return json.MarshalIndent(node, "", "  ")
+	case FormatYAML:
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(node); err != nil {
+			return nil, err
+		}
+		enc.Close()
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("composite: unknown tree format %d", format)
+}
+
+// DecodeTree decodes a tree in the given format
+func DecodeTree(data []byte, format Format) (*ProductBundle, error) {
+	switch format {
+	case FormatJSON:
+		return decodeJSON(data)
+	case FormatYAML:
+		return decodeNested(data)
+	case FormatCSV:
+		return decodeCSV(data)
+	}
+	return nil, fmt.Errorf("composite: unknown tree format %d", format)
+}
+
+// position turns a byte offset into a 1-based line and column
+func position(data []byte, offset int64) (line, col int) {
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = int(offset) - (bytes.LastIndexByte(before, '\n') + 1)
+	return line, max(col, 1)
+}
+
+func toNode(p Product) (productNode, error) {
+	switch p := p.(type) {
+	case *SingleProduct:
+		node := productNode{
+			Type:     typeProduct,
+			ID:       p.id,
+			Name:     p.name,
+			Price:    p.price,
+			Quantity: p.quantity,
+			Weight:   p.weight,
+			TaxClass: p.taxClass,
+		}
+		if p.dimensions != (Dimensions{}) {
+			dims := p.dimensions
+			node.Dimensions = &dims
+		}
+		return node, nil
+	case *ProductBundle:
+		node := productNode{Type: typeBundle, ID: p.id}
+		specs, err := policySpecs(p)
+		if err != nil {
+			return node, err
+		}
+		node.Policies = specs
+		for _, child := range p.products {
+			c, err := toNode(child)
+			if err != nil {
+				return node, err
+			}
+			node.Products = append(node.Products, c)
+		}
+		return node, nil
+	}
+	return productNode{}, fmt.Errorf("composite: cannot encode product of type %T", p)
+}
+
+func policySpecs(b *ProductBundle) ([]policySpec, error) {
+	var specs []policySpec
+	for _, policy := range b.policies {
+		bp, ok := policy.(builtinPolicy)
+		if !ok {
+			return nil, fmt.Errorf("composite: cannot encode pricing policy %T of bundle %s", policy, describe(b))
+		}
+		specs = append(specs, bp.spec)
+	}
+	return specs, nil
+}
+
+// newPolicy rebuilds a built-in policy from its spec
+func newPolicy(spec policySpec) (PricingPolicy, error) {
+	switch spec.Kind {
+	case "buy-n-get-m":
+		return BuyNGetM(spec.N, spec.M), nil
+	case "percent-off":
+		return PercentOff(spec.Percent), nil
+	case "cheapest-free":
+		return CheapestFree(spec.MinItems), nil
+	case "capped-price":
+		return CappedPrice(spec.Limit), nil
+	}
+	return nil, fmt.Errorf("unknown policy kind %q", spec.Kind)
+}
+
+// decodeNested reads YAML through yaml.Node, which keeps the position of every value
+func decodeNested(data []byte) (*ProductBundle, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, yamlError(err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, &ParseError{Line: 1, Column: 1, Err: errors.New("empty document")}
+	}
+	return decodeRoot(doc.Content[0])
+}
+
+// yamlSyntaxError matches the messages yaml.v3 gives for malformed documents
+var yamlSyntaxError = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// yamlError turns a yaml.v3 error into a *ParseError. yaml.v3 reports the line of a syntax error only in its message
+// and never the column.
+func yamlError(err error) error {
+	line, msg := 1, err.Error()
+	if m := yamlSyntaxError.FindStringSubmatch(msg); m != nil {
+		line, _ = strconv.Atoi(m[1])
+		msg = m[2]
+	}
+	return &ParseError{Line: line, Column: 1, Err: errors.New(msg)}
+}
+
+// decodeJSON reads JSON token by token with encoding/json and builds the same yaml.Node tree as decodeNested, so both
+// formats share fromNode. Positions come from the decoder's input offset.
+func decodeJSON(data []byte) (*ProductBundle, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if !dec.More() {
+		return nil, &ParseError{Line: 1, Column: 1, Err: errors.New("empty document")}
+	}
+	root, err := readJSON(dec, data)
+	if err != nil {
+		return nil, err
+	}
+	start := tokenStart(data, dec.InputOffset())
+	if _, err := dec.Token(); err != io.EOF {
+		line, col := position(data, start+1)
+		return nil, &ParseError{Line: line, Column: col, Err: errors.New("unexpected data after the tree")}
+	}
+	return decodeRoot(root)
+}
+
+// tokenStart skips the whitespace and separators that encoding/json consumes before the token at offset
+func tokenStart(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// readJSON reads one JSON value into a yaml.Node
+func readJSON(dec *json.Decoder, data []byte) (*yaml.Node, error) {
+	n := &yaml.Node{}
+	n.Line, n.Column = position(data, tokenStart(data, dec.InputOffset())+1)
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, jsonError(data, dec, err)
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		n.Kind, n.Tag = yaml.MappingNode, "!!map"
+		if tok == '[' {
+			n.Kind, n.Tag = yaml.SequenceNode, "!!seq"
+		}
+		for dec.More() {
+			child, err := readJSON(dec, data)
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, child)
+		}
+		// the closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return nil, jsonError(data, dec, err)
+		}
+	case string:
+		n.Kind, n.Tag, n.Value = yaml.ScalarNode, "!!str", tok
+	case json.Number:
+		n.Kind, n.Tag, n.Value = yaml.ScalarNode, "!!int", tok.String()
+		if strings.ContainsAny(n.Value, ".eE") {
+			n.Tag = "!!float"
+		}
+	case bool:
+		n.Kind, n.Tag, n.Value = yaml.ScalarNode, "!!bool", strconv.FormatBool(tok)
+	case nil:
+		n.Kind, n.Tag, n.Value = yaml.ScalarNode, "!!null", "null"
+	}
+	return n, nil
+}
+
+func jsonError(data []byte, dec *json.Decoder, err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		line, col := position(data, syntaxErr.Offset)
+		return &ParseError{Line: line, Column: col, Err: err}
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		line, col := position(data, int64(len(data)))
+		return &ParseError{Line: line, Column: col, Err: io.ErrUnexpectedEOF}
+	}
+	line, col := position(data, dec.InputOffset())
+	return &ParseError{Line: line, Column: col, Err: err}
+}
+
+// decodeRoot builds the tree from the root node, which must be a bundle
+func decodeRoot(n *yaml.Node) (*ProductBundle, error) {
+	root, err := fromNode(n)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := root.(*ProductBundle)
+	if !ok {
+		return nil, nodeError(n, "type", errors.New("the root must be a bundle"))
+	}
+	return b, nil
+}
+
+func nodeError(n *yaml.Node, field string, err error) *ParseError {
+	return &ParseError{Line: n.Line, Column: n.Column, Field: field, Err: err}
+}
+
+// decodeFields decodes a mapping into the given fields. A field that points to a *yaml.Node receives the raw node.
+func decodeFields(n *yaml.Node, fields map[string]any) error {
+	if n.Kind != yaml.MappingNode {
+		return nodeError(n, "", errors.New("expected an object"))
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		ptr, ok := fields[key.Value]
+		if !ok {
+			return nodeError(key, key.Value, errors.New("unknown field"))
+		}
+		if raw, ok := ptr.(**yaml.Node); ok {
+			*raw = value
+			continue
+		}
+		if value.Kind != yaml.ScalarNode || value.Decode(ptr) != nil {
+			return nodeError(value, key.Value, fmt.Errorf("invalid value %q", value.Value))
+		}
+		// yaml.v3 truncates a float decoded into an int, so 2.7 would silently become 2
+		if _, isInt := ptr.(*int); isInt && value.ShortTag() != "!!int" {
+			return nodeError(value, key.Value, fmt.Errorf("expected an integer, got %q", value.Value))
+		}
+	}
+	return nil
+}
+
+func fromNode(n *yaml.Node) (Product, error) {
+	var (
+		node                     productNode
+		dims, policies, products *yaml.Node
+		length, width, height    float64
+	)
+	err := decodeFields(n, map[string]any{
+		"type":       &node.Type,
+		"id":         &node.ID,
+		"name":       &node.Name,
+		"price":      &node.Price,
+		"quantity":   &node.Quantity,
+		"weight":     &node.Weight,
+		"dimensions": &dims,
+		"tax_class":  &node.TaxClass,
+		"policies":   &policies,
+		"products":   &products,
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch node.Type {
+	case typeProduct:
+		if policies != nil || products != nil {
+			return nil, nodeError(n, "type", errors.New("a product cannot have policies or products"))
+		}
+		p := &SingleProduct{id: node.ID, name: node.Name, price: node.Price, quantity: node.Quantity,
+			weight: node.Weight, taxClass: node.TaxClass}
+		if dims != nil {
+			err := decodeFields(dims, map[string]any{"length": &length, "width": &width, "height": &height})
+			if err != nil {
+				return nil, err
+			}
+			p.dimensions = Dimensions{Length: length, Width: width, Height: height}
+		}
+		return p, nil
+	case typeBundle:
+		if node.Name != "" || node.Price != 0 || node.Quantity != 0 || node.Weight != 0 || dims != nil || node.TaxClass != "" {
+			return nil, nodeError(n, "type", errors.New("a bundle only has an id, policies and products"))
+		}
+		b := NewProductBundle(node.ID)
+		if err := decodePolicies(b, policies); err != nil {
+			return nil, err
+		}
+		if products == nil {
+			return b, nil
+		}
+		if products.Kind != yaml.SequenceNode {
+			return nil, nodeError(products, "products", errors.New("expected a list"))
+		}
+		for _, c := range products.Content {
+			child, err := fromNode(c)
+			if err != nil {
+				return nil, err
+			}
+			b.AddProduct(child)
+		}
+		return b, nil
+	case "":
+		return nil, nodeError(n, "type", errors.New("missing"))
+	}
+	return nil, nodeError(n, "type", fmt.Errorf("unknown type %q", node.Type))
+}
+
+func decodePolicies(b *ProductBundle, n *yaml.Node) error {
+	if n == nil {
+		return nil
+	}
+	if n.Kind != yaml.SequenceNode {
+		return nodeError(n, "policies", errors.New("expected a list"))
+	}
+	for _, pn := range n.Content {
+		var spec policySpec
+		err := decodeFields(pn, map[string]any{
+			"kind":      &spec.Kind,
+			"n":         &spec.N,
+			"m":         &spec.M,
+			"percent":   &spec.Percent,
+			"min_items": &spec.MinItems,
+			"limit":     &spec.Limit,
+		})
+		if err != nil {
+			return err
+		}
+		policy, err := newPolicy(spec)
+		if err != nil {
+			return nodeError(pn, "kind", err)
+		}
+		b.AddPolicy(policy)
+	}
+	return nil
+}
+
+var csvHeader = []string{"parent", "type", "id", "name", "price", "quantity", "weight", "length", "width", "height", "tax_class", "policies"}
+
+func encodeCSV(root *ProductBundle) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(csvHeader)
+	var write func(parent string, p Product) error
+	write = func(parent string, p Product) error {
+		switch p := p.(type) {
+		case *SingleProduct:
+			return w.Write([]string{parent, typeProduct, p.id, p.name, formatFloat(p.price), formatInt(p.quantity),
+				formatFloat(p.weight), formatFloat(p.dimensions.Length), formatFloat(p.dimensions.Width),
+				formatFloat(p.dimensions.Height), string(p.taxClass), ""})
+		case *ProductBundle:
+			if p.id == "" || strings.Contains(p.id, "/") {
+				return fmt.Errorf("composite: bundle ID %q cannot be used in a CSV path", p.id)
+			}
+			path := joinPath(parent, p.id)
+			seen := map[string]bool{}
+			for _, child := range p.products {
+				if c, ok := child.(*ProductBundle); ok {
+					if seen[c.id] {
+						return fmt.Errorf("composite: bundle %q has two bundles with ID %q", path, c.id)
+					}
+					seen[c.id] = true
+				}
+			}
+			specs, err := policySpecs(p)
+			if err != nil {
+				return err
+			}
+			policies := ""
+			if len(specs) > 0 {
+				b, err := json.Marshal(specs)
+				if err != nil {
+					return err
+				}
+				policies = string(b)
+			}
+			if err := w.Write([]string{parent, typeBundle, p.id, "", "", "", "", "", "", "", "", policies}); err != nil {
+				return err
+			}
+			for _, child := range p.products {
+				if err := write(path, child); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return fmt.Errorf("composite: cannot encode product of type %T", p)
+	}
+	if err := write("", root); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func joinPath(parent, id string) string {
+	if parent == "" {
+		return id
+	}
+	return parent + "/" + id
+}
+
+// formatFloat writes zero as an empty cell
+func formatFloat(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func formatInt(i int) string {
+	if i == 0 {
+		return ""
+	}
+	return strconv.Itoa(i)
+}
+
+func decodeCSV(data []byte) (*ProductBundle, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = len(csvHeader)
+	header, err := r.Read()
+	if err != nil {
+		return nil, csvError(err)
+	}
+	for i, name := range csvHeader {
+		if header[i] != name {
+			line, col := r.FieldPos(i)
+			return nil, &ParseError{Line: line, Column: col, Field: name, Err: fmt.Errorf("expected column %q, found %q", name, header[i])}
+		}
+	}
+
+	var root *ProductBundle
+	bundles := map[string]*ProductBundle{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, csvError(err)
+		}
+		// fail reports a problem with column i of the current record
+		fail := func(i int, err error) error {
+			line, col := r.FieldPos(i)
+			return &ParseError{Line: line, Column: col, Field: csvHeader[i], Err: err}
+		}
+		cell := func(i int) string { return record[i] }
+		number := func(i int) (float64, error) {
+			if cell(i) == "" {
+				return 0, nil
+			}
+			f, err := strconv.ParseFloat(cell(i), 64)
+			if err != nil {
+				return 0, fail(i, fmt.Errorf("invalid number %q", cell(i)))
+			}
+			return f, nil
+		}
+
+		var product Product
+		switch cell(1) {
+		case typeProduct:
+			p := &SingleProduct{id: cell(2), name: cell(3), taxClass: TaxClass(cell(10))}
+			if p.price, err = number(4); err != nil {
+				return nil, err
+			}
+			if cell(5) != "" {
+				if p.quantity, err = strconv.Atoi(cell(5)); err != nil {
+					return nil, fail(5, fmt.Errorf("invalid quantity %q", cell(5)))
+				}
+			}
+			for i, f := range []*float64{&p.weight, &p.dimensions.Length, &p.dimensions.Width, &p.dimensions.Height} {
+				if *f, err = number(6 + i); err != nil {
+					return nil, err
+				}
+			}
+			if cell(11) != "" {
+				return nil, fail(11, errors.New("a product cannot have policies"))
+			}
+			product = p
+		case typeBundle:
+			for i := 3; i <= 10; i++ {
+				if cell(i) != "" {
+					return nil, fail(i, errors.New("a bundle only has an id and policies"))
+				}
+			}
+			if cell(2) == "" || strings.Contains(cell(2), "/") {
+				return nil, fail(2, errors.New(`a bundle needs an ID without "/"`))
+			}
+			b := NewProductBundle(cell(2))
+			if cell(11) != "" {
+				var specs []policySpec
+				dec := json.NewDecoder(strings.NewReader(cell(11)))
+				dec.DisallowUnknownFields()
+				if err := dec.Decode(&specs); err != nil {
+					return nil, fail(11, err)
+				}
+				for _, spec := range specs {
+					policy, err := newPolicy(spec)
+					if err != nil {
+						return nil, fail(11, err)
+					}
+					b.AddPolicy(policy)
+				}
+			}
+			path := joinPath(cell(0), b.id)
+			if bundles[path] != nil {
+				return nil, fail(2, fmt.Errorf("duplicate bundle %q", path))
+			}
+			bundles[path] = b
+			product = b
+		default:
+			return nil, fail(1, fmt.Errorf("unknown type %q", cell(1)))
+		}
+
+		if root == nil {
+			b, ok := product.(*ProductBundle)
+			if !ok || cell(0) != "" {
+				return nil, fail(0, errors.New("the first row must be the root bundle, without a parent"))
+			}
+			root = b
+			continue
+		}
+		parent, ok := bundles[cell(0)]
+		if !ok {
+			return nil, fail(0, fmt.Errorf("unknown parent bundle %q", cell(0)))
+		}
+		parent.AddProduct(product)
+	}
+	if root == nil {
+		return nil, &ParseError{Line: 2, Column: 1, Err: errors.New("no root bundle")}
+	}
+	return root, nil
+}
+
+func csvError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &ParseError{Line: parseErr.Line, Column: parseErr.Column, Err: parseErr.Err}
+	}
+	if err == io.EOF {
+		return &ParseError{Line: 1, Column: 1, Err: errors.New("missing header")}
+	}
+	return fmt.Errorf("composite: parsing CSV tree: %w", err)
+}
@@ -0,0 +1,133 @@
+package composite
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+)
+
+func sampleTree() *ProductBundle {
+	root := NewProductBundle("shop")
+	root.AddPolicy(PercentOff(10))
+	gifts := NewProductBundle("gifts")
+	gifts.AddPolicy(BuyNGetM(2, 1))
+	gifts.AddProduct(NewSingleProduct("TEA-01", 4, WithName("Green tea"), WithQuantity(3), WithTaxClass(TaxReduced)))
+	gifts.AddProduct(NewSingleProduct("MUG-01", 12, WithWeight(0.4), WithDimensions(Dimensions{Length: 10, Width: 8, Height: 9})))
+	root.AddProduct(gifts)
+	root.AddProduct(NewSingleProduct("CARD", 2.5))
+	return root
+}
+
+func TestTreeRoundTrip(t *testing.T) {
+	want := sampleTree()
+	for name, format := range map[string]Format{"json": FormatJSON, "yaml": FormatYAML, "csv": FormatCSV} {
+		t.Run(name, func(t *testing.T) {
+			data, err := EncodeTree(want, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := DecodeTree(data, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got.GetPrice()-want.GetPrice()) > 1e-9 {
+				t.Errorf("GetPrice() = %v, want %v", got.GetPrice(), want.GetPrice())
+			}
+			compareTrees(t, want.ID(), got, want)
+		})
+	}
+}
+
+// compareTrees reports every field that differs between two trees, including the ones RenderTree does not show
+func compareTrees(t *testing.T, path string, got, want Product) {
+	t.Helper()
+	switch want := want.(type) {
+	case *SingleProduct:
+		got, ok := got.(*SingleProduct)
+		if !ok {
+			t.Errorf("%s: got %T, want a product", path, got)
+			return
+		}
+		// the parent pointers differ between two trees; every other field must match
+		g, w := *got, *want
+		g.parent, w.parent = nil, nil
+		if g != w {
+			t.Errorf("%s = %+v, want %+v", path, g, w)
+		}
+	case *ProductBundle:
+		got, ok := got.(*ProductBundle)
+		if !ok {
+			t.Errorf("%s: got %T, want a bundle", path, got)
+			return
+		}
+		if got.id != want.id {
+			t.Errorf("%s: ID = %q, want %q", path, got.id, want.id)
+		}
+		gotSpecs, _ := policySpecs(got)
+		wantSpecs, _ := policySpecs(want)
+		if !slices.Equal(gotSpecs, wantSpecs) {
+			t.Errorf("%s: policies = %+v, want %+v", path, gotSpecs, wantSpecs)
+		}
+		if len(got.products) != len(want.products) {
+			t.Errorf("%s: %d products, want %d", path, len(got.products), len(want.products))
+			return
+		}
+		for i := range want.products {
+			compareTrees(t, fmt.Sprintf("%s/%d", path, i), got.products[i], want.products[i])
+		}
+	}
+}
+
+func TestDecodeJSONEscapes(t *testing.T) {
+	root, err := DecodeTree([]byte(`{"type":"bundle","id":"a\/b","products":[{"type":"product","id":"café","price":1.5e1}]}`), FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.ID() != "a/b" || root.products[0].(*SingleProduct).ID() != "café" || root.GetPrice() != 15 {
+		t.Errorf("decoded %q with %v", root.ID(), RenderTree(root))
+	}
+}
+
+func TestDecodeTreeErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		format       Format
+		input        string
+		line, column int
+		field        string
+	}{
+		{"json empty", FormatJSON, "", 1, 1, ""},
+		{"json truncated", FormatJSON, `{"type":"bundle",`, 1, 17, ""},
+		{"json missing comma", FormatJSON, `{"type":"bundle" "id":"x"}`, 1, 18, ""},
+		{"json trailing data", FormatJSON, `{"type":"bundle"} {}`, 1, 19, ""},
+		{"json wrong type", FormatJSON, "{\"type\":\"bundle\",\n  \"products\":[{\"type\":\"product\",\"price\":\"4\"}]}", 2, 41, "price"},
+		{"json unknown field", FormatJSON, "{\n  \"type\": \"bundle\",\n  \"colour\": \"red\"\n}", 3, 3, "colour"},
+		{"json product root", FormatJSON, `{"type":"product","id":"x"}`, 1, 1, "type"},
+		{"json unknown policy", FormatJSON, `{"type":"bundle","policies":[{"kind":"bogo"}]}`, 1, 30, "kind"},
+		{"json fractional quantity", FormatJSON, `{"type":"bundle","products":[{"type":"product","quantity":2.7}]}`, 1, 59, "quantity"},
+		{"json exponent quantity", FormatJSON, `{"type":"bundle","products":[{"type":"product","quantity":2e0}]}`, 1, 59, "quantity"},
+		{"json fractional policy", FormatJSON, `{"type":"bundle","policies":[{"kind":"buy-n-get-m","n":2.5,"m":1}]}`, 1, 56, "n"},
+		{"yaml fractional quantity", FormatYAML, "type: bundle\nproducts:\n  - type: product\n    quantity: 2.7\n", 4, 15, "quantity"},
+		{"yaml fractional policy", FormatYAML, "type: bundle\npolicies:\n  - kind: cheapest-free\n    min_items: 3.5\n", 4, 16, "min_items"},
+		{"csv fractional quantity", FormatCSV, "parent,type,id,name,price,quantity,weight,length,width,height,tax_class,policies\n,bundle,root,,,,,,,,,\nroot,product,x,,1,2.7,,,,,,\n", 3, 19, "quantity"},
+		{"yaml syntax", FormatYAML, "type: bundle\n  id: [x\n", 2, 1, ""},
+		{"yaml unknown type", FormatYAML, "type: bundle\nproducts:\n  - type: service\n", 3, 5, "type"},
+		{"csv bad price", FormatCSV, "parent,type,id,name,price,quantity,weight,length,width,height,tax_class,policies\n,bundle,root,,,,,,,,,\nroot,product,x,,abc,,,,,,,\n", 3, 17, "price"},
+		{"csv unknown parent", FormatCSV, "parent,type,id,name,price,quantity,weight,length,width,height,tax_class,policies\n,bundle,root,,,,,,,,,\nnope,product,x,,1,,,,,,,\n", 3, 1, "parent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeTree([]byte(tt.input), tt.format)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("err = %v (%T), want a *ParseError", err, err)
+			}
+			if parseErr.Line != tt.line || parseErr.Column != tt.column || parseErr.Field != tt.field {
+				t.Errorf("got line %d, column %d, field %q, want %d, %d, %q (%v)",
+					parseErr.Line, parseErr.Column, parseErr.Field, tt.line, tt.column, tt.field, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package composite
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Bundles can be restructured: products are removed by identity or by ID, replaced, and moved between bundles. Every
+// SingleProduct and ProductBundle knows the bundle it belongs to, so a product is in at most one bundle at a time, and
+// insertions that would put a bundle inside itself are rejected with a *CycleError.
+
+var (
+	// ErrHasParent is returned when adding a product that already belongs to a bundle
+	ErrHasParent = errors.New("composite: product already belongs to a bundle")
+	// ErrNotChild is returned when a product is not a direct child of the bundle
+	ErrNotChild = errors.New("composite: product is not a child of the bundle")
+)
+
+// CycleError is returned when adding Product to Bundle would make the bundle contain itself
+type CycleError struct {
+	Bundle  *ProductBundle
+	Product Product
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("composite: adding %s to %s would create a cycle", describe(e.Product), describe(e.Bundle))
+}
+
+// describe names a product by its ID, for error messages
+func describe(p Product) string {
+	if p, ok := p.(interface{ ID() string }); ok && p.ID() != "" {
+		return fmt.Sprintf("%q", p.ID())
+	}
+	return fmt.Sprintf("%T", p)
+}
+
+// ID returns the product's ID
+func (p *SingleProduct) ID() string {
+	return p.id
+}
+
+// Parent returns the bundle the product belongs to, or nil
+func (p *SingleProduct) Parent() *ProductBundle {
+	return p.parent
+}
+
+// ID returns the bundle's ID
+func (b *ProductBundle) ID() string {
+	return b.id
+}
+
+// Parent returns the bundle this bundle belongs to, or nil
+func (b *ProductBundle) Parent() *ProductBundle {
+	return b.parent
+}
+
+// setParent records the parent of products that track it
+func setParent(p Product, parent *ProductBundle) {
+	switch p := p.(type) {
+	case *SingleProduct:
+		p.parent = parent
+	case *ProductBundle:
+		p.parent = parent
+	}
+}
+
+func parentOf(p Product) *ProductBundle {
+	if p, ok := p.(interface{ Parent() *ProductBundle }); ok {
+		return p.Parent()
+	}
+	return nil
+}
+
+// checkInsert reports whether p may become a child of b. A cycle is reported before ErrHasParent, so inserting a
+// bundle into itself or its own subtree is always a *CycleError.
+func (b *ProductBundle) checkInsert(p Product) error {
+	if p == nil {
+		return errors.New("composite: nil product")
+	}
+	if err := b.checkCycle(p); err != nil {
+		return err
+	}
+	if parentOf(p) != nil {
+		return ErrHasParent
+	}
+	return nil
+}
+
+// checkCycle fails if b is p or somewhere inside p
+func (b *ProductBundle) checkCycle(p Product) error {
+	for _, q := range DepthFirst(p) {
+		if q == Product(b) {
+			return &CycleError{Bundle: b, Product: p}
+		}
+	}
+	return nil
+}
+
+func (b *ProductBundle) indexOf(p Product) int {
+	for i, q := range b.products {
+		if q == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// Remove removes p, compared by identity, from the bundle's direct children. It reports whether p was found.
+func (b *ProductBundle) Remove(p Product) bool {
+	i := b.indexOf(p)
+	if i < 0 {
+		return false
+	}
+	b.products = slices.Delete(b.products, i, i+1)
+	setParent(p, nil)
+	b.invalidate()
+	return true
+}
+
+// FindByID returns the path to the first product in the tree, depth first, with the given ID
+func (b *ProductBundle) FindByID(id string) (Path, bool) {
+	return Find(b, func(p Product) bool {
+		q, ok := p.(interface{ ID() string })
+		return ok && q.ID() == id
+	})
+}
+
+// RemoveByID removes the first product below the bundle, depth first, with the given ID, together with its subtree.
+// It returns the removed product.
+func (b *ProductBundle) RemoveByID(id string) (Product, bool) {
+	path, ok := b.FindByID(id)
+	if !ok || path.Depth() == 0 {
+		return nil, false
+	}
+	parent, ok := path.Parent().Product().(*ProductBundle)
+	if !ok {
+		return nil, false
+	}
+	p := path.Product()
+	return p, parent.Remove(p)
+}
+
+// Replace puts replacement in the place of old, a direct child of the bundle. old is detached from the bundle.
+func (b *ProductBundle) Replace(old, replacement Product) error {
+	i := b.indexOf(old)
+	if i < 0 {
+		return ErrNotChild
+	}
+	if old == replacement {
+		return nil
+	}
+	if err := b.checkInsert(replacement); err != nil {
+		return err
+	}
+	b.products[i] = replacement
+	setParent(old, nil)
+	setParent(replacement, b)
+	b.invalidate()
+	return nil
+}
+
+// Move detaches p from its current bundle, if any, and appends it to b. Nothing changes if the move fails.
+func (b *ProductBundle) Move(p Product) error {
+	if err := b.checkCycle(p); err != nil {
+		return err
+	}
+	if from := parentOf(p); from != nil {
+		from.Remove(p)
+	}
+	return b.AddProduct(p)
+}
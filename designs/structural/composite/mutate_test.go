@@ -0,0 +1,124 @@
+package composite
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestRemoveByIdentity(t *testing.T) {
+	b := NewProductBundle("box")
+	first, second := NewSingleProduct("dup", 1), NewSingleProduct("dup", 2)
+	b.AddProduct(first)
+	b.AddProduct(second)
+
+	// Remove compares by identity, so the second product goes even though the first has the same ID
+	if !b.Remove(second) {
+		t.Fatal("Remove(second) = false")
+	}
+	if b.Len() != 1 || b.products[0] != Product(first) || second.Parent() != nil || first.Parent() != b {
+		t.Errorf("after Remove: %d products, parents %v and %v", b.Len(), first.Parent(), second.Parent())
+	}
+	if b.GetPrice() != 1 {
+		t.Errorf("GetPrice() = %v after Remove, want 1", b.GetPrice())
+	}
+	if b.Remove(second) {
+		t.Error("removing it again succeeded")
+	}
+}
+
+func TestRemoveByID(t *testing.T) {
+	root := sampleTree()
+	gifts := root.products[0].(*ProductBundle)
+	before := root.GetPrice()
+
+	// the first match depth first is removed, together with its subtree
+	root.AddProduct(NewSingleProduct("MUG-01", 100))
+	removed, ok := root.RemoveByID("MUG-01")
+	if !ok || removed.GetPrice() != 12 || gifts.Len() != 1 || root.Len() != 3 {
+		t.Fatalf("RemoveByID(MUG-01) = %v, %v; gifts has %d, root has %d", removed, ok, gifts.Len(), root.Len())
+	}
+	if parentOf(removed) != nil {
+		t.Error("the removed product still has a parent")
+	}
+	removed, ok = root.RemoveByID("gifts")
+	if !ok || removed != Product(gifts) || gifts.Parent() != nil || gifts.Len() != 1 {
+		t.Errorf("RemoveByID(gifts) = %v, %v", removed, ok)
+	}
+	// CARD and the new MUG-01 are left, 10% off
+	if got := root.GetPrice(); math.Abs(got-92.25) > 1e-9 || got == before {
+		t.Errorf("GetPrice() = %v, want 92.25", got)
+	}
+}
+
+func TestRemoveMissing(t *testing.T) {
+	root := sampleTree()
+	other := NewProductBundle("other")
+	elsewhere := NewSingleProduct("elsewhere", 1)
+	other.AddProduct(elsewhere)
+
+	if root.Remove(elsewhere) || elsewhere.Parent() != other || other.Len() != 1 {
+		t.Error("Remove took a product from another bundle")
+	}
+	if root.Remove(NewSingleProduct("loose", 1)) {
+		t.Error("Remove of a product without a bundle succeeded")
+	}
+	// a grandchild is not a direct child
+	if root.Remove(root.products[0].(*ProductBundle).products[0]) {
+		t.Error("Remove of a grandchild succeeded")
+	}
+	for _, id := range []string{"nope", "shop"} {
+		if p, ok := root.RemoveByID(id); ok || p != nil {
+			t.Errorf("RemoveByID(%q) = %v, %v", id, p, ok)
+		}
+	}
+	if err := root.Replace(elsewhere, NewSingleProduct("new", 1)); !errors.Is(err, ErrNotChild) {
+		t.Errorf("Replace of a stranger: err = %v, want ErrNotChild", err)
+	}
+}
+
+func TestInsertRejectsCycles(t *testing.T) {
+	root := sampleTree()
+	gifts := root.products[0].(*ProductBundle)
+	inner := NewProductBundle("inner")
+	gifts.AddProduct(inner)
+	price := root.GetPrice()
+
+	tests := []struct {
+		name    string
+		insert  func() error
+		bundle  *ProductBundle
+		product Product
+	}{
+		{"add itself", func() error { return root.AddProduct(root) }, root, root},
+		{"add a bundle with a parent to itself", func() error { return inner.AddProduct(inner) }, inner, inner},
+		{"replace a child with itself", func() error { return gifts.Replace(inner, gifts) }, gifts, gifts},
+		{"move itself", func() error { return inner.Move(inner) }, inner, inner},
+		{"move an ancestor inside", func() error { return inner.Move(gifts) }, inner, gifts},
+		{"move the root inside", func() error { return inner.Move(root) }, inner, root},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cycle *CycleError
+			if err := tt.insert(); !errors.As(err, &cycle) {
+				t.Fatalf("err = %v, want a *CycleError", err)
+			}
+			if cycle.Bundle != tt.bundle || cycle.Product != tt.product {
+				t.Errorf("CycleError = %v", cycle)
+			}
+		})
+	}
+
+	// nothing moved
+	if gifts.Parent() != root || inner.Parent() != gifts || root.Len() != 2 || gifts.Len() != 3 || root.GetPrice() != price {
+		t.Errorf("a rejected insert changed the tree:\n%s", RenderTree(root))
+	}
+
+	// a bundle that belongs elsewhere cannot be added, but Move reparents it
+	if err := root.AddProduct(inner); !errors.Is(err, ErrHasParent) {
+		t.Errorf("AddProduct of a child of gifts: err = %v, want ErrHasParent", err)
+	}
+	if err := root.Move(inner); err != nil || inner.Parent() != root || gifts.Len() != 2 {
+		t.Errorf("Move = %v, parent %v", err, inner.Parent())
+	}
+}
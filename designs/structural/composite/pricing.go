@@ -0,0 +1,213 @@
+package composite
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// A bundle's price is the sum of its children unless it has pricing policies. Policies run in the order they were
+// added, each starting from the total left by the one before, and see the prices of the bundle's items: its direct
+// children after they applied their own policies, so discounts nest, with a SingleProduct counted once per unit at its
+// unit price. A line of three mugs is three mugs to BuyNGetM, not one expensive item. Explain records every step.
+
+// PricingPolicy adjusts the total of a bundle
+type PricingPolicy interface {
+	// Apply returns the new total given the item prices and the current total, with a short description of what it
+	// did
+	Apply(prices []float64, total float64) (float64, string)
+}
+
+// PolicyFunc lets an ordinary function be used as a PricingPolicy
+type PolicyFunc func(prices []float64, total float64) (float64, string)
+
+func (f PolicyFunc) Apply(prices []float64, total float64) (float64, string) {
+	return f(prices, total)
+}
+
+// policySpec describes a built-in policy, so that it can be encoded
+type policySpec struct {
+	Kind     string  `json:"kind" yaml:"kind"`
+	N        int     `json:"n,omitempty" yaml:"n,omitempty"`
+	M        int     `json:"m,omitempty" yaml:"m,omitempty"`
+	Percent  float64 `json:"percent,omitempty" yaml:"percent,omitempty"`
+	MinItems int     `json:"min_items,omitempty" yaml:"min_items,omitempty"`
+	Limit    float64 `json:"limit,omitempty" yaml:"limit,omitempty"`
+}
+
+// builtinPolicy is a policy created by one of the constructors below
+type builtinPolicy struct {
+	PolicyFunc
+	spec policySpec
+}
+
+// AddPolicy adds pricing policies to the bundle, applied after the ones it already has
+func (b *ProductBundle) AddPolicy(policies ...PricingPolicy) {
+	b.policies = append(b.policies, policies...)
+	b.invalidate()
+}
+
+// Policies returns the bundle's pricing policies in the order they are applied
+func (b *ProductBundle) Policies() []PricingPolicy {
+	return slices.Clone(b.policies)
+}
+
+// BuyNGetM makes m of every n+m items free. Items are grouped from the most expensive down and the cheapest m
+// of each full group are free, so BuyNGetM(2, 1) is "3 for 2".
+func BuyNGetM(n, m int) PricingPolicy {
+	return builtinPolicy{
+		spec: policySpec{Kind: "buy-n-get-m", N: n, M: m},
+		PolicyFunc: func(prices []float64, total float64) (float64, string) {
+			if n <= 0 || m <= 0 {
+				return total, "no discount"
+			}
+			sorted := slices.Clone(prices)
+			slices.SortFunc(sorted, cmpDesc)
+			free := 0.0
+			for k := 0; k+n+m <= len(sorted); k += n + m {
+				for _, price := range sorted[k+n : k+n+m] {
+					free += price
+				}
+			}
+			return total - free, fmt.Sprintf("buy %d get %d free: -%.2f", n, m, free)
+		},
+	}
+}
+
+func cmpDesc(a, b float64) int {
+	switch {
+	case a > b:
+		return -1
+	case a < b:
+		return 1
+	}
+	return 0
+}
+
+// PercentOff takes percent off the total, rounded to cents
+func PercentOff(percent float64) PricingPolicy {
+	return builtinPolicy{
+		spec: policySpec{Kind: "percent-off", Percent: percent},
+		PolicyFunc: func(_ []float64, total float64) (float64, string) {
+			off := roundCents(total * percent / 100)
+			return total - off, fmt.Sprintf("%g%% off: -%.2f", percent, off)
+		},
+	}
+}
+
+// CheapestFree makes the cheapest item free once the bundle has at least minItems items
+func CheapestFree(minItems int) PricingPolicy {
+	return builtinPolicy{
+		spec: policySpec{Kind: "cheapest-free", MinItems: minItems},
+		PolicyFunc: func(prices []float64, total float64) (float64, string) {
+			if len(prices) == 0 || len(prices) < minItems {
+				return total, fmt.Sprintf("cheapest free: needs %d items", minItems)
+			}
+			cheapest := slices.Min(prices)
+			return total - cheapest, fmt.Sprintf("cheapest free: -%.2f", cheapest)
+		},
+	}
+}
+
+// CappedPrice limits the total to limit
+func CappedPrice(limit float64) PricingPolicy {
+	return builtinPolicy{
+		spec: policySpec{Kind: "capped-price", Limit: limit},
+		PolicyFunc: func(_ []float64, total float64) (float64, string) {
+			if total <= limit {
+				return total, fmt.Sprintf("capped at %.2f: not reached", limit)
+			}
+			return limit, fmt.Sprintf("capped at %.2f: -%.2f", limit, total-limit)
+		},
+	}
+}
+
+func roundCents(x float64) float64 {
+	return math.Round(x*100) / 100
+}
+
+// appendItems appends the item prices of a child priced at price. A SingleProduct is one item per unit, anything else
+// is a single item.
+func appendItems(items []float64, child Product, price float64) []float64 {
+	p, ok := child.(*SingleProduct)
+	if !ok || p.Quantity() == 1 {
+		return append(items, price)
+	}
+	unit := price / float64(p.Quantity())
+	for range p.Quantity() {
+		items = append(items, unit)
+	}
+	return items
+}
+
+// applyPolicies runs the bundle's policies over its subtotal, recording each step in trace if it is not nil. The
+// total never drops below zero.
+func (b *ProductBundle) applyPolicies(prices []float64, total float64, trace *PriceTrace) float64 {
+	for _, policy := range b.policies {
+		after, note := policy.Apply(prices, total)
+		after = max(after, 0)
+		if trace != nil {
+			trace.Steps = append(trace.Steps, TraceStep{Note: note, Before: total, After: after})
+		}
+		total = after
+	}
+	return total
+}
+
+// PriceTrace explains how the price of a product was computed
+type PriceTrace struct {
+	Product  Product
+	Subtotal float64
+	Steps    []TraceStep
+	Price    float64
+	Children []*PriceTrace
+}
+
+// TraceStep is the effect of one pricing policy
+type TraceStep struct {
+	Note          string
+	Before, After float64
+}
+
+// Explain computes the price of p like GetPrice and records how every bundle in the tree arrived at its price
+func Explain(p Product) *PriceTrace {
+	b, ok := p.(*ProductBundle)
+	if !ok {
+		price := p.GetPrice()
+		return &PriceTrace{Product: p, Subtotal: price, Price: price}
+	}
+	trace := &PriceTrace{Product: b}
+	prices := make([]float64, 0, len(b.products))
+	for _, child := range b.products {
+		ct := Explain(child)
+		trace.Children = append(trace.Children, ct)
+		prices = appendItems(prices, child, ct.Price)
+		trace.Subtotal += ct.Price
+	}
+	trace.Price = b.applyPolicies(prices, trace.Subtotal, trace)
+	return trace
+}
+
+// String renders the trace as an indented tree
+func (t *PriceTrace) String() string {
+	var sb strings.Builder
+	t.write(&sb, 0)
+	return sb.String()
+}
+
+func (t *PriceTrace) write(sb *strings.Builder, depth int) {
+	indent := strings.Repeat("  ", depth)
+	if len(t.Children) == 0 && len(t.Steps) == 0 {
+		fmt.Fprintf(sb, "%s%s %.2f\n", indent, describe(t.Product), t.Price)
+		return
+	}
+	fmt.Fprintf(sb, "%s%s subtotal %.2f\n", indent, describe(t.Product), t.Subtotal)
+	for _, c := range t.Children {
+		c.write(sb, depth+1)
+	}
+	for _, s := range t.Steps {
+		fmt.Fprintf(sb, "%s  %s → %.2f\n", indent, s.Note, s.After)
+	}
+	fmt.Fprintf(sb, "%s= %.2f\n", indent, t.Price)
+}
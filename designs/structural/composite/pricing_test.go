@@ -0,0 +1,104 @@
+package composite
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPoliciesCountUnits(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   PricingPolicy
+		products []*SingleProduct
+		want     float64
+	}{
+		{
+			name:     "3 for 2 on one line",
+			policy:   BuyNGetM(2, 1),
+			products: []*SingleProduct{NewSingleProduct("mug", 10, WithQuantity(3))},
+			want:     20,
+		},
+		{
+			name:   "3 for 2 across lines",
+			policy: BuyNGetM(2, 1),
+			products: []*SingleProduct{
+				NewSingleProduct("mug", 10, WithQuantity(2)),
+				NewSingleProduct("card", 3),
+			},
+			want: 20,
+		},
+		{
+			name:   "3 for 2 frees the cheapest units",
+			policy: BuyNGetM(2, 1),
+			products: []*SingleProduct{
+				NewSingleProduct("mug", 10, WithQuantity(4)),
+				NewSingleProduct("card", 3, WithQuantity(2)),
+			},
+			want: 10*4 + 3*2 - 10 - 3,
+		},
+		{
+			name:     "not enough units",
+			policy:   BuyNGetM(2, 1),
+			products: []*SingleProduct{NewSingleProduct("mug", 10, WithQuantity(2))},
+			want:     20,
+		},
+		{
+			name:     "cheapest free counts units",
+			policy:   CheapestFree(3),
+			products: []*SingleProduct{NewSingleProduct("tea", 4, WithQuantity(3))},
+			want:     8,
+		},
+		{
+			name:   "cheapest free takes one unit",
+			policy: CheapestFree(2),
+			products: []*SingleProduct{
+				NewSingleProduct("tea", 4, WithQuantity(3)),
+				NewSingleProduct("kettle", 30),
+			},
+			want: 38,
+		},
+		{
+			name:     "cheapest free needs enough units",
+			policy:   CheapestFree(4),
+			products: []*SingleProduct{NewSingleProduct("tea", 4, WithQuantity(3))},
+			want:     12,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewProductBundle("basket")
+			for _, p := range tt.products {
+				b.AddProduct(p)
+			}
+			b.AddPolicy(tt.policy)
+			for name, got := range map[string]float64{
+				"GetPrice":         b.GetPrice(),
+				"GetPriceUncached": b.GetPriceUncached(),
+				"Explain":          Explain(b).Price,
+			} {
+				if math.Abs(got-tt.want) > 1e-9 {
+					t.Errorf("%s = %v, want %v", name, got, tt.want)
+				}
+			}
+			base := 0.0
+			for _, amount := range b.TaxBase() {
+				base += amount
+			}
+			if math.Abs(base-tt.want) > 1e-9 {
+				t.Errorf("TaxBase adds up to %v, want %v", base, tt.want)
+			}
+		})
+	}
+}
+
+func TestNestedBundleIsOneItem(t *testing.T) {
+	inner := NewProductBundle("set")
+	inner.AddProduct(NewSingleProduct("cup", 5, WithQuantity(2)))
+	outer := NewProductBundle("order")
+	outer.AddProduct(inner)
+	outer.AddProduct(NewSingleProduct("saucer", 2))
+	outer.AddPolicy(CheapestFree(2))
+	if got := outer.GetPrice(); got != 10 {
+		t.Errorf("GetPrice() = %v, want 10", got)
+	}
+}
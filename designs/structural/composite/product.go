@@ -0,0 +1,177 @@
+package composite
+
+// Besides its ID (the SKU) and unit price, a SingleProduct has a name, a quantity, a unit weight and size, and a tax
+// class, all set with options on NewSingleProduct. Bundles aggregate them over the whole tree.
+
+// TaxClass groups products taxed at the same rate
+type TaxClass string
+
+const (
+	TaxStandard TaxClass = "standard"
+	TaxReduced  TaxClass = "reduced"
+	TaxExempt   TaxClass = "exempt"
+)
+
+// Dimensions is the size of one unit, in centimetres
+type Dimensions struct {
+	Length float64 `json:"length" yaml:"length"`
+	Width  float64 `json:"width" yaml:"width"`
+	Height float64 `json:"height" yaml:"height"`
+}
+
+// Volume returns the volume in cubic centimetres
+func (d Dimensions) Volume() float64 {
+	return d.Length * d.Width * d.Height
+}
+
+// ProductOption configures a SingleProduct
+type ProductOption func(*SingleProduct)
+
+// WithName sets the display name
+func WithName(name string) ProductOption {
+	return func(p *SingleProduct) {
+		p.name = name
+	}
+}
+
+// WithQuantity sets how many units the line holds. Values below 1 mean 1.
+func WithQuantity(quantity int) ProductOption {
+	return func(p *SingleProduct) {
+		p.quantity = quantity
+	}
+}
+
+// WithWeight sets the weight of one unit, in kilograms
+func WithWeight(kg float64) ProductOption {
+	return func(p *SingleProduct) {
+		p.weight = kg
+	}
+}
+
+// WithDimensions sets the size of one unit
+func WithDimensions(d Dimensions) ProductOption {
+	return func(p *SingleProduct) {
+		p.dimensions = d
+	}
+}
+
+// WithTaxClass sets the tax class. The default is TaxStandard.
+func WithTaxClass(class TaxClass) ProductOption {
+	return func(p *SingleProduct) {
+		p.taxClass = class
+	}
+}
+
+// SKU returns the product's ID
+func (p *SingleProduct) SKU() string {
+	return p.id
+}
+
+// Name returns the display name, or the SKU if it has none
+func (p *SingleProduct) Name() string {
+	if p.name == "" {
+		return p.id
+	}
+	return p.name
+}
+
+// Quantity returns the number of units, at least 1
+func (p *SingleProduct) Quantity() int {
+	return max(p.quantity, 1)
+}
+
+// UnitPrice returns the price of one unit
+func (p *SingleProduct) UnitPrice() float64 {
+	return p.price
+}
+
+// Weight returns the weight of all units, in kilograms
+func (p *SingleProduct) Weight() float64 {
+	return p.weight * float64(p.Quantity())
+}
+
+// Dimensions returns the size of one unit
+func (p *SingleProduct) Dimensions() Dimensions {
+	return p.dimensions
+}
+
+// TaxClass returns the product's tax class
+func (p *SingleProduct) TaxClass() TaxClass {
+	if p.taxClass == "" {
+		return TaxStandard
+	}
+	return p.taxClass
+}
+
+// TotalWeight returns the weight of every product in the tree, in kilograms
+func (b *ProductBundle) TotalWeight() float64 {
+	total := 0.0
+	for _, p := range DepthFirst(b) {
+		if p, ok := p.(*SingleProduct); ok {
+			total += p.Weight()
+		}
+	}
+	return total
+}
+
+// ItemCount returns the number of units in the tree. Leaves other than SingleProduct count as one unit.
+func (b *ProductBundle) ItemCount() int {
+	count := 0
+	for _, p := range DepthFirst(b) {
+		switch p := p.(type) {
+		case *SingleProduct:
+			count += p.Quantity()
+		case container:
+		default:
+			count++
+		}
+	}
+	return count
+}
+
+// TaxBase returns the amount of the bundle's price that falls in each tax class. Bundle discounts are shared out over
+// the classes in proportion to their prices, so the amounts add up to GetPrice. Leaves other than SingleProduct count
+// as TaxStandard.
+func (b *ProductBundle) TaxBase() map[TaxClass]float64 {
+	return taxBase(b)
+}
+
+func taxBase(p Product) map[TaxClass]float64 {
+	b, ok := p.(*ProductBundle)
+	if !ok {
+		class := TaxStandard
+		if p, ok := p.(*SingleProduct); ok {
+			class = p.TaxClass()
+		}
+		return map[TaxClass]float64{class: p.GetPrice()}
+	}
+	base := map[TaxClass]float64{}
+	subtotal := 0.0
+	prices := make([]float64, 0, len(b.products))
+	for _, child := range b.products {
+		for class, amount := range taxBase(child) {
+			base[class] += amount
+		}
+		price := child.GetPrice()
+		prices = appendItems(prices, child, price)
+		subtotal += price
+	}
+	if price := b.applyPolicies(prices, subtotal, nil); price != subtotal && subtotal > 0 {
+		for class := range base {
+			base[class] *= price / subtotal
+		}
+	}
+	return base
+}
+
+// TaxByClass returns the tax owed per class at the given rates, rounded to cents. A rate of 0.2 is 20%. Classes
+// without a rate are untaxed.
+func (b *ProductBundle) TaxByClass(rates map[TaxClass]float64) map[TaxClass]float64 {
+	tax := map[TaxClass]float64{}
+	for class, amount := range b.TaxBase() {
+		if rate, ok := rates[class]; ok {
+			tax[class] = roundCents(amount * rate)
+		}
+	}
+	return tax
+}
@@ -0,0 +1,62 @@
+package composite
+
+import (
+	"math"
+	"testing"
+)
+
+func TestItemCountAndTotalWeight(t *testing.T) {
+	nested := func() *ProductBundle {
+		inner := NewProductBundle("inner")
+		inner.AddProduct(NewSingleProduct("bolt", 0.1, WithQuantity(10), WithWeight(0.05)))
+		inner.AddProduct(NewProductBundle("empty"))
+		middle := NewProductBundle("middle")
+		middle.AddProduct(inner)
+		middle.AddProduct(NewSingleProduct("nut", 0.05, WithQuantity(4), WithWeight(0.01)))
+		root := NewProductBundle("root")
+		root.AddProduct(middle)
+		root.AddProduct(NewSingleProduct("box", 2, WithWeight(0.3)))
+		root.AddProduct(flatFee(1))
+		return root
+	}
+
+	tests := []struct {
+		name   string
+		bundle *ProductBundle
+		items  int
+		weight float64
+	}{
+		{"empty", NewProductBundle("empty"), 0, 0},
+		{"quantity of one", func() *ProductBundle {
+			b := NewProductBundle("b")
+			b.AddProduct(NewSingleProduct("mug", 12, WithWeight(0.4)))
+			return b
+		}(), 1, 0.4},
+		{"quantity above one", func() *ProductBundle {
+			b := NewProductBundle("b")
+			b.AddProduct(NewSingleProduct("tea", 4, WithQuantity(3), WithWeight(0.2)))
+			b.AddProduct(NewSingleProduct("mug", 12, WithWeight(0.4)))
+			return b
+		}(), 4, 1.0},
+		// 10 bolts + 4 nuts + 1 box + 1 fee; bundles count for nothing themselves
+		{"nested bundles", nested(), 16, 10*0.05 + 4*0.01 + 0.3},
+		{"sample tree", sampleTree(), 5, 0.4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.bundle.ItemCount(); got != tt.items {
+				t.Errorf("ItemCount() = %d, want %d", got, tt.items)
+			}
+			if got := tt.bundle.TotalWeight(); math.Abs(got-tt.weight) > 1e-9 {
+				t.Errorf("TotalWeight() = %v, want %v", got, tt.weight)
+			}
+		})
+	}
+
+	// a quantity below one still counts as one unit
+	b := NewProductBundle("b")
+	b.AddProduct(NewSingleProduct("x", 1, WithQuantity(0), WithWeight(2)))
+	if b.ItemCount() != 1 || b.TotalWeight() != 2 {
+		t.Errorf("quantity 0: ItemCount() = %d, TotalWeight() = %v", b.ItemCount(), b.TotalWeight())
+	}
+}
@@ -0,0 +1,200 @@
+package composite
+
+import (
+	"fmt"
+	"iter"
+	"strings"
+)
+
+// A product tree can be walked in three ways: the DepthFirst and BreadthFirst iterators, which yield every product
+// together with its Path from the root, and a Visitor, which is told whether each product is a leaf or a bundle.
+//
+// Any Product with a Products method is treated as a bundle, so other composites can be walked too.
+
+// container is a Product that holds other products
+type container interface {
+	Product
+	Products() []Product
+}
+
+// Products returns the bundle's direct children, in the order they were added
+func (b *ProductBundle) Products() []Product {
+	return append([]Product(nil), b.products...)
+}
+
+// Len returns the number of direct children
+func (b *ProductBundle) Len() int {
+	return len(b.products)
+}
+
+// Step is one level of a Path: a product and its position in its parent. The root has Index -1.
+type Step struct {
+	Index   int
+	Product Product
+}
+
+// Path leads from the root of a tree to a product
+type Path []Step
+
+// Product returns the product the path leads to, or nil for an empty path
+func (p Path) Product() Product {
+	if len(p) == 0 {
+		return nil
+	}
+	return p[len(p)-1].Product
+}
+
+// Depth returns the number of steps below the root
+func (p Path) Depth() int {
+	return len(p) - 1
+}
+
+// Parent returns the path to the parent, or nil for the root
+func (p Path) Parent() Path {
+	if len(p) <= 1 {
+		return nil
+	}
+	return p[:len(p)-1]
+}
+
+// String renders the path as "root → bundle[1] → item[0]", with each product's index in its parent
+func (p Path) String() string {
+	parts := make([]string, len(p))
+	for i, step := range p {
+		if i == 0 {
+			parts[i] = "root"
+			continue
+		}
+		parts[i] = fmt.Sprintf("%s[%d]", label(step.Product), step.Index)
+	}
+	return strings.Join(parts, " → ")
+}
+
+func label(p Product) string {
+	if _, ok := p.(container); ok {
+		return "bundle"
+	}
+	return "item"
+}
+
+// child returns a new path one step below p. The result never shares its backing array with p, so paths yielded by
+// the iterators can be kept.
+func (p Path) child(index int, product Product) Path {
+	next := make(Path, len(p), len(p)+1)
+	copy(next, p)
+	return append(next, Step{Index: index, Product: product})
+}
+
+// DepthFirst yields every product in the tree in pre-order: a bundle comes before its children
+func DepthFirst(root Product) iter.Seq2[Path, Product] {
+	return func(yield func(Path, Product) bool) {
+		var walk func(Path) bool
+		walk = func(path Path) bool {
+			p := path.Product()
+			if !yield(path, p) {
+				return false
+			}
+			if c, ok := p.(container); ok {
+				for i, child := range c.Products() {
+					if !walk(path.child(i, child)) {
+						return false
+					}
+				}
+			}
+			return true
+		}
+		walk(Path{{Index: -1, Product: root}})
+	}
+}
+
+// BreadthFirst yields every product in the tree level by level
+func BreadthFirst(root Product) iter.Seq2[Path, Product] {
+	return func(yield func(Path, Product) bool) {
+		queue := []Path{{{Index: -1, Product: root}}}
+		for len(queue) > 0 {
+			path := queue[0]
+			queue = queue[1:]
+			p := path.Product()
+			if !yield(path, p) {
+				return
+			}
+			if c, ok := p.(container); ok {
+				for i, child := range c.Products() {
+					queue = append(queue, path.child(i, child))
+				}
+			}
+		}
+	}
+}
+
+// Find returns the path to the first product, depth first, for which match returns true
+func Find(root Product, match func(Product) bool) (Path, bool) {
+	for path, p := range DepthFirst(root) {
+		if match(p) {
+			return path, true
+		}
+	}
+	return nil, false
+}
+
+// Visitor is called for every product in a tree by Accept
+type Visitor interface {
+	VisitProduct(p *SingleProduct, path Path)
+	// VisitBundle returns false to skip the bundle's children
+	VisitBundle(b *ProductBundle, path Path) bool
+}
+
+// visitable is a Product that can be walked by a Visitor
+type visitable interface {
+	Product
+	accept(v Visitor, path Path)
+}
+
+// Accept calls v.VisitProduct for the product
+func (p *SingleProduct) Accept(v Visitor) {
+	p.accept(v, Path{{Index: -1, Product: p}})
+}
+
+func (p *SingleProduct) accept(v Visitor, path Path) {
+	v.VisitProduct(p, path)
+}
+
+// Accept walks the bundle depth first, calling v for the bundle and every product in it. Products of other types are
+// skipped.
+func (b *ProductBundle) Accept(v Visitor) {
+	b.accept(v, Path{{Index: -1, Product: b}})
+}
+
+func (b *ProductBundle) accept(v Visitor, path Path) {
+	if !v.VisitBundle(b, path) {
+		return
+	}
+	for i, child := range b.products {
+		if c, ok := child.(visitable); ok {
+			c.accept(v, path.child(i, child))
+		}
+	}
+}
+
+// RenderTree renders the tree one product per line, indented by depth, with prices. Like Accept, it skips products
+// that are neither a SingleProduct nor a ProductBundle.
+func RenderTree(root Product) string {
+	r := &treeRenderer{}
+	if v, ok := root.(visitable); ok {
+		v.accept(r, Path{{Index: -1, Product: root}})
+	}
+	return r.b.String()
+}
+
+type treeRenderer struct {
+	b strings.Builder
+}
+
+func (r *treeRenderer) VisitProduct(p *SingleProduct, path Path) {
+	fmt.Fprintf(&r.b, "%s%s $%.2f\n", strings.Repeat("  ", path.Depth()), label(p), p.GetPrice())
+}
+
+func (r *treeRenderer) VisitBundle(b *ProductBundle, path Path) bool {
+	fmt.Fprintf(&r.b, "%s%s $%.2f\n", strings.Repeat("  ", path.Depth()), label(b), b.GetPrice())
+	return true
+}
@@ -0,0 +1,190 @@
+package composite
+
+import (
+	"iter"
+	"slices"
+	"testing"
+)
+
+// flatFee is a leaf that is neither a SingleProduct nor a ProductBundle
+type flatFee float64
+
+func (f flatFee) GetPrice() float64 { return float64(f) }
+
+func idOf(p Product) string {
+	if p, ok := p.(interface{ ID() string }); ok {
+		return p.ID()
+	}
+	return "fee"
+}
+
+// traversalTree is sampleTree with a flat fee added to the gifts bundle
+func traversalTree() *ProductBundle {
+	root := sampleTree()
+	root.products[0].(*ProductBundle).AddProduct(flatFee(1))
+	return root
+}
+
+func TestTraversalOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		walk  func(Product) iter.Seq2[Path, Product]
+		ids   []string
+		paths []string
+	}{
+		{
+			"depth first",
+			DepthFirst,
+			[]string{"shop", "gifts", "TEA-01", "MUG-01", "fee", "CARD"},
+			[]string{"root", "root → bundle[0]", "root → bundle[0] → item[0]", "root → bundle[0] → item[1]",
+				"root → bundle[0] → item[2]", "root → item[1]"},
+		},
+		{
+			"breadth first",
+			BreadthFirst,
+			[]string{"shop", "gifts", "CARD", "TEA-01", "MUG-01", "fee"},
+			[]string{"root", "root → bundle[0]", "root → item[1]", "root → bundle[0] → item[0]",
+				"root → bundle[0] → item[1]", "root → bundle[0] → item[2]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := traversalTree()
+			var ids, paths []string
+			var kept []Path
+			for path, p := range tt.walk(root) {
+				if path.Product() != p {
+					t.Errorf("path %v leads to %v, not %v", path, path.Product(), p)
+				}
+				ids = append(ids, idOf(p))
+				paths = append(paths, path.String())
+				kept = append(kept, path)
+			}
+			if !slices.Equal(ids, tt.ids) {
+				t.Errorf("order = %v, want %v", ids, tt.ids)
+			}
+			if !slices.Equal(paths, tt.paths) {
+				t.Errorf("paths = %q, want %q", paths, tt.paths)
+			}
+			// paths kept after the walk still lead to their products
+			for i, path := range kept {
+				if idOf(path.Product()) != tt.ids[i] {
+					t.Errorf("kept path %d now leads to %s", i, idOf(path.Product()))
+				}
+			}
+
+			// stopping early does not walk any further
+			var visited []string
+			for _, p := range tt.walk(root) {
+				visited = append(visited, idOf(p))
+				if len(visited) == 3 {
+					break
+				}
+			}
+			if !slices.Equal(visited, tt.ids[:3]) {
+				t.Errorf("after break visited %v, want %v", visited, tt.ids[:3])
+			}
+		})
+	}
+
+	// a leaf on its own is a tree of one
+	for path, p := range DepthFirst(NewSingleProduct("solo", 1)) {
+		if idOf(p) != "solo" || path.Depth() != 0 || path.String() != "root" {
+			t.Errorf("single product walk yielded %v at %v", idOf(p), path)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	root := sampleTree()
+	path, ok := Find(root, func(p Product) bool { return idOf(p) == "MUG-01" })
+	if !ok {
+		t.Fatal("MUG-01 not found")
+	}
+	if path.Depth() != 2 || idOf(path.Product()) != "MUG-01" || path[2].Index != 1 {
+		t.Errorf("path = %v with depth %d", path, path.Depth())
+	}
+	if parent := path.Parent(); idOf(parent.Product()) != "gifts" || idOf(parent.Parent().Product()) != "shop" {
+		t.Errorf("parents = %v", parent)
+	}
+	if rootPath := path.Parent().Parent(); rootPath.Parent() != nil || rootPath[0].Index != -1 {
+		t.Errorf("root path = %v", rootPath)
+	}
+	if (Path{}).Product() != nil || (Path{}).Parent() != nil {
+		t.Error("an empty path leads somewhere")
+	}
+}
+
+func TestFind(t *testing.T) {
+	root := sampleTree()
+	tests := []struct {
+		name  string
+		match func(Product) bool
+		want  string
+		found bool
+	}{
+		{"root", func(p Product) bool { return p == Product(root) }, "shop", true},
+		{"first match depth first", func(p Product) bool { _, ok := p.(*SingleProduct); return ok }, "TEA-01", true},
+		{"by price", func(p Product) bool { return p.GetPrice() == 2.5 }, "CARD", true},
+		{"not found", func(p Product) bool { return idOf(p) == "nope" }, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, ok := Find(root, tt.match)
+			if ok != tt.found || (ok && idOf(path.Product()) != tt.want) || (!ok && path != nil) {
+				t.Errorf("Find = %v, %v, want %s, %v", path, ok, tt.want, tt.found)
+			}
+		})
+	}
+	if _, ok := root.FindByID("MUG-01"); !ok {
+		t.Error("FindByID(MUG-01) found nothing")
+	}
+	if path, ok := root.FindByID("nope"); ok || path != nil {
+		t.Errorf("FindByID(nope) = %v, %v", path, ok)
+	}
+}
+
+// recordingVisitor records every call and skips the children of the bundles in skip
+type recordingVisitor struct {
+	calls []string
+	skip  string
+}
+
+func (v *recordingVisitor) VisitProduct(p *SingleProduct, path Path) {
+	v.calls = append(v.calls, "product "+p.ID()+" at "+path.String())
+}
+
+func (v *recordingVisitor) VisitBundle(b *ProductBundle, path Path) bool {
+	v.calls = append(v.calls, "bundle "+b.ID()+" at "+path.String())
+	return b.ID() != v.skip
+}
+
+func TestAccept(t *testing.T) {
+	root := traversalTree()
+	v := &recordingVisitor{}
+	root.Accept(v)
+	// the flat fee is not a visitable product and is skipped
+	want := []string{
+		"bundle shop at root",
+		"bundle gifts at root → bundle[0]",
+		"product TEA-01 at root → bundle[0] → item[0]",
+		"product MUG-01 at root → bundle[0] → item[1]",
+		"product CARD at root → item[1]",
+	}
+	if !slices.Equal(v.calls, want) {
+		t.Errorf("calls = %q, want %q", v.calls, want)
+	}
+
+	v = &recordingVisitor{skip: "gifts"}
+	root.Accept(v)
+	want = []string{"bundle shop at root", "bundle gifts at root → bundle[0]", "product CARD at root → item[1]"}
+	if !slices.Equal(v.calls, want) {
+		t.Errorf("calls skipping gifts = %q, want %q", v.calls, want)
+	}
+
+	v = &recordingVisitor{}
+	NewSingleProduct("solo", 1).Accept(v)
+	if !slices.Equal(v.calls, []string{"product solo at root"}) {
+		t.Errorf("calls = %q", v.calls)
+	}
+}
@@ -0,0 +1,303 @@
+package decorator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MilkDecorator and SugarDecorator are hand-written types with their price and description baked in. A Catalog holds the
+// same information as data, loaded from a JSON or YAML menu file, and produces AddOnDecorators at runtime so new add-ons
+// do not need new Go types.
+//
+// A menu file looks like this:
+//
+//	currency: USD
+//	addons:
+//	  - name: oat-milk
+//	    description: oat milk
+//	    price: "0.75"
+//	    max_quantity: 1
+//	    excludes: [milk]
+//	  - name: vanilla-syrup
+//	    description: vanilla syrup
+//	    price: "0.60"
+//	    max_quantity: 3
+
+// Format is the encoding of a menu file
+type Format int
+
+const (
+	FormatJSON Format = iota
+	FormatYAML
+)
+
+// AddOn describes one add-on of a menu
+type AddOn struct {
+	// Name identifies the add-on, e.g. "oat-milk"
+	Name string
+	// Description is appended to the drink's description, e.g. "oat milk"
+	Description string
+	// Price is added to the cost of the drink
+	Price Money
+	// MaxQuantity is the maximum number of times the add-on can be applied to one drink. Zero means unlimited.
+	MaxQuantity int
+	// Excludes lists layers that cannot be combined with this add-on
+	Excludes []string
+	// Requires lists layers that must already be on the drink before this add-on is applied
+	Requires []string
+}
+
+// UnknownAddOnError is returned when a name is not on the menu
+type UnknownAddOnError struct {
+	Name string
+}
+
+func (e *UnknownAddOnError) Error() string {
+	return fmt.Sprintf("decorator: unknown add-on %q", e.Name)
+}
+
+// builtinLayers are the layer names that menus may refer to without defining them
+var builtinLayers = []string{"milk", "sugar"}
+
+// Catalog is a set of add-ons loaded from a menu
+type Catalog struct {
+	currency Currency
+	addOns   map[string]*AddOn
+	names    []string
+}
+
+// menuFile is the on-disk layout of a menu
+type menuFile struct {
+	Currency Currency    `json:"currency" yaml:"currency"`
+	AddOns   []menuAddOn `json:"addons" yaml:"addons"`
+}
+
+type menuAddOn struct {
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description" yaml:"description"`
+	Price       decimal  `json:"price" yaml:"price"`
+	MaxQuantity int      `json:"max_quantity" yaml:"max_quantity"`
+	Excludes    []string `json:"excludes" yaml:"excludes"`
+	Requires    []string `json:"requires" yaml:"requires"`
+}
+
+// decimal accepts prices written either as JSON numbers or strings and keeps their exact text for ParseMoney
+type decimal string
+
+func (d *decimal) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*d = decimal(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return fmt.Errorf("decorator: price must be a number or string: %w", err)
+	}
+	*d = decimal(n)
+	return nil
+}
+
+// LoadCatalog reads a menu file. The format is picked from the extension: .json, .yaml or .yml.
+func LoadCatalog(path string) (*Catalog, error) {
+	var format Format
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		format = FormatJSON
+	case ".yaml", ".yml":
+		format = FormatYAML
+	default:
+		return nil, fmt.Errorf("decorator: unsupported menu file %q", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCatalog(data, format)
+}
+
+// ParseCatalog decodes a menu in the given format
+func ParseCatalog(data []byte, format Format) (*Catalog, error) {
+	var menu menuFile
+	switch format {
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&menu); err != nil {
+			return nil, fmt.Errorf("decorator: parsing JSON menu: %w", err)
+		}
+	case FormatYAML:
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&menu); err != nil {
+			return nil, fmt.Errorf("decorator: parsing YAML menu: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("decorator: unknown menu format %d", format)
+	}
+	return newCatalog(menu)
+}
+
+func newCatalog(menu menuFile) (*Catalog, error) {
+	if menu.Currency == "" {
+		return nil, errors.New("decorator: menu has no currency")
+	}
+	c := &Catalog{currency: menu.Currency, addOns: make(map[string]*AddOn)}
+	for i, m := range menu.AddOns {
+		if m.Name == "" {
+			return nil, fmt.Errorf("decorator: add-on %d has no name", i)
+		}
+		if slices.Contains(builtinLayers, m.Name) {
+			return nil, fmt.Errorf("decorator: add-on name %q is reserved for a built-in layer", m.Name)
+		}
+		if _, dup := c.addOns[m.Name]; dup {
+			return nil, fmt.Errorf("decorator: add-on %q is defined twice", m.Name)
+		}
+		if m.MaxQuantity < 0 {
+			return nil, fmt.Errorf("decorator: add-on %q has a negative max_quantity", m.Name)
+		}
+		price, err := ParseMoney(string(m.Price), menu.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("decorator: add-on %q: %w", m.Name, err)
+		}
+		c.addOns[m.Name] = &AddOn{
+			Name:        m.Name,
+			Description: m.Description,
+			Price:       price,
+			MaxQuantity: m.MaxQuantity,
+			Excludes:    m.Excludes,
+			Requires:    m.Requires,
+		}
+		c.names = append(c.names, m.Name)
+	}
+	// references are checked once every add-on is known so they can point forwards
+	for _, a := range c.addOns {
+		for _, ref := range slices.Concat(a.Excludes, a.Requires) {
+			if _, ok := c.addOns[ref]; !ok && !slices.Contains(builtinLayers, ref) {
+				return nil, fmt.Errorf("decorator: add-on %q refers to unknown add-on %q", a.Name, ref)
+			}
+		}
+	}
+	return c, nil
+}
+
+// Currency returns the currency of every price on the menu
+func (c *Catalog) Currency() Currency {
+	return c.currency
+}
+
+// Names returns the add-on names in menu order
+func (c *Catalog) Names() []string {
+	return slices.Clone(c.names)
+}
+
+// Lookup returns the add-on with the given name
+func (c *Catalog) Lookup(name string) (AddOn, bool) {
+	a, ok := c.addOns[name]
+	if !ok {
+		return AddOn{}, false
+	}
+	return *a, true
+}
+
+// CheckCurrency returns a *CurrencyMismatchError if the drink is priced in a different currency than the menu
+func (c *Catalog) CheckCurrency(coffee Coffee) error {
+	if cur := coffee.Cost().Currency(); cur != "" && cur != c.currency {
+		return &CurrencyMismatchError{Left: cur, Right: c.currency}
+	}
+	return nil
+}
+
+// Decorator returns a Decorator[Coffee] that applies the named add-on. It does not check the add-on's rules; use Apply
+// or Rules for that. Wrapping a drink in another currency panics with a *CurrencyMismatchError straight away rather
+// than on the first Cost call; use CheckCurrency or Apply to get an error instead.
+func (c *Catalog) Decorator(name string) (Decorator[Coffee], error) {
+	a, ok := c.addOns[name]
+	if !ok {
+		return nil, &UnknownAddOnError{Name: name}
+	}
+	return func(inner Coffee) Coffee {
+		if err := c.CheckCurrency(inner); err != nil {
+			panic(err)
+		}
+		return NewAddOnDecorator(inner, a)
+	}, nil
+}
+
+// Rules returns the quantity, exclusion and requirement rules of every add-on on the menu
+func (c *Catalog) Rules() *Rules {
+	rules := &Rules{MaxCount: make(map[string]int), Requires: make(map[string][]string)}
+	for _, name := range c.names {
+		a := c.addOns[name]
+		if a.MaxQuantity > 0 {
+			rules.MaxCount[name] = a.MaxQuantity
+		}
+		for _, ex := range a.Excludes {
+			rules.Exclusive = append(rules.Exclusive, []string{name, ex})
+		}
+		if len(a.Requires) > 0 {
+			rules.Requires[name] = a.Requires
+		}
+	}
+	return rules
+}
+
+// Apply adds the named add-ons to the drink in order and validates the result against the catalog's Rules.
+// Rule violations are reported together in a *ValidationError, and a drink in another currency than the menu gives a
+// *CurrencyMismatchError.
+func (c *Catalog) Apply(coffee Coffee, names ...string) (Coffee, error) {
+	if err := c.CheckCurrency(coffee); err != nil {
+		return nil, err
+	}
+	decorators := make([]Decorator[Coffee], len(names))
+	for i, name := range names {
+		d, err := c.Decorator(name)
+		if err != nil {
+			return nil, err
+		}
+		decorators[i] = d
+	}
+	return c.Rules().Apply(coffee, decorators...)
+}
+
+// AddOnDecorator is a ConcreteDecorator whose price and description come from a catalog AddOn
+type AddOnDecorator struct {
+	*CoffeeDecorator
+	addOn *AddOn
+}
+
+// NewAddOnDecorator wraps a Coffee with a catalog add-on
+func NewAddOnDecorator(c Coffee, a *AddOn) *AddOnDecorator {
+	return &AddOnDecorator{&CoffeeDecorator{c}, a}
+}
+
+func (d *AddOnDecorator) Cost() Money {
+	return mustAdd(d.CoffeeDecorator.Cost(), d.addOn.Price)
+}
+
+func (d *AddOnDecorator) Description() string {
+	return d.CoffeeDecorator.Description() + ", " + d.addOn.Description
+}
+
+// Name returns the add-on name
+func (d *AddOnDecorator) Name() string {
+	return d.addOn.Name
+}
+
+// AddOn returns a copy of the add-on applied by this layer
+func (d *AddOnDecorator) AddOn() AddOn {
+	return *d.addOn
+}
+
+// Rewrap returns a new AddOnDecorator with the same add-on around the given Coffee
+func (d *AddOnDecorator) Rewrap(inner Coffee) Coffee {
+	return NewAddOnDecorator(inner, d.addOn)
+}
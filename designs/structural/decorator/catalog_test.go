@@ -0,0 +1,57 @@
+package decorator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCatalogCurrencyMismatch(t *testing.T) {
+	eur, err := ParseCatalog([]byte(`{"currency":"EUR","addons":[{"name":"oat-milk","description":"oat milk","price":"0.70"}]}`), FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mismatch *CurrencyMismatchError
+	if _, err := eur.Apply(&SimpleCoffee{}, "oat-milk"); !errors.As(err, &mismatch) || mismatch.Left != "USD" || mismatch.Right != "EUR" {
+		t.Errorf("Apply: err = %v, want a USD/EUR CurrencyMismatchError", err)
+	}
+
+	layer, err := eur.Layer("oat-milk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err, _ := recover().(error); !errors.As(err, &mismatch) {
+			t.Errorf("Layer: recovered %v, want a CurrencyMismatchError", err)
+		}
+	}()
+	NewDrink(&SimpleCoffee{}).With(layer)
+	t.Error("adding a EUR layer to a USD drink did not panic")
+}
+
+func TestCatalogApply(t *testing.T) {
+	usd, err := ParseCatalog([]byte("currency: USD\naddons:\n  - name: oat-milk\n    description: oat milk\n    price: 0.75\n"), FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := usd.Apply(&SimpleCoffee{}, "oat-milk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Description() != "Simple coffee, oat milk" {
+		t.Errorf("Description() = %q", c.Description())
+	}
+}
+
+func TestCatalogReservedName(t *testing.T) {
+	for _, name := range builtinLayers {
+		_, err := ParseCatalog([]byte(`{"currency":"USD","addons":[{"name":"`+name+`","price":"1"}]}`), FormatJSON)
+		if err == nil || !strings.Contains(err.Error(), "reserved") {
+			t.Errorf("%s: err = %v, want a reserved name error", name, err)
+		}
+	}
+	_, err := ParseCatalog([]byte(`{"currency":"USD","addons":[{"name":"x","price":"1"},{"name":"x","price":"2"}]}`), FormatJSON)
+	if err == nil || !strings.Contains(err.Error(), "defined twice") {
+		t.Errorf("duplicate: err = %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package decorator
+
+// The Coffee types below hard-code one component interface. The same stacking works for any interface once a decorator is
+// expressed as a function that takes a component and returns a wrapped component of the same type. Decorator[T] is that
+// function, and Chain[T] is an ordered list of them that can be applied to any component.
+//
+// Every Coffee decorator has a Decorator[Coffee] form below, so any drink can be built from a CoffeeChain. The
+// decorator types themselves stay concrete structs: Layers, Remove and the serializers need their Unwrap, Rewrap and Name
+// methods, which a bare function cannot carry. A Chain is therefore opaque until it is applied; inspect the Coffee it
+// returns, not the Chain.
+
+// Decorator wraps a component of type T and returns the decorated component
+type Decorator[T any] func(T) T
+
+// Chain is an ordered list of decorators. The first decorator is applied first, so it ends up innermost.
+type Chain[T any] struct {
+	decorators []Decorator[T]
+}
+
+// NewChain creates a chain from the given decorators
+func NewChain[T any](decorators ...Decorator[T]) *Chain[T] {
+	return &Chain[T]{decorators: append([]Decorator[T](nil), decorators...)}
+}
+
+// Then returns a new chain with the given decorators appended after the existing ones
+func (c *Chain[T]) Then(decorators ...Decorator[T]) *Chain[T] {
+	next := make([]Decorator[T], 0, len(c.decorators)+len(decorators))
+	next = append(next, c.decorators...)
+	next = append(next, decorators...)
+	return &Chain[T]{decorators: next}
+}
+
+// Len returns the number of decorators in the chain
+func (c *Chain[T]) Len() int {
+	return len(c.decorators)
+}
+
+// Apply wraps the component with every decorator in the chain, in order
+func (c *Chain[T]) Apply(component T) T {
+	return Apply(component, c.decorators...)
+}
+
+// Apply wraps the component with the given decorators, in order. Nil decorators are skipped.
+func Apply[T any](component T, decorators ...Decorator[T]) T {
+	for _, d := range decorators {
+		if d == nil {
+			continue
+		}
+		component = d(component)
+	}
+	return component
+}
+
+// CoffeeChain is a chain of Coffee decorators
+type CoffeeChain = Chain[Coffee]
+
+// WithMilk is the MilkDecorator expressed as a Decorator[Coffee]
+func WithMilk(c Coffee) Coffee {
+	return NewMilkDecorator(c)
+}
+
+// WithSugar is the SugarDecorator expressed as a Decorator[Coffee]
+func WithSugar(c Coffee) Coffee {
+	return NewSugarDecorator(c)
+}
+
+// WithAddOn returns the AddOnDecorator for a as a Decorator[Coffee]
+func WithAddOn(a *AddOn) Decorator[Coffee] {
+	return func(c Coffee) Coffee {
+		return NewAddOnDecorator(c, a)
+	}
+}
+
+// WithSize returns the SizeDecorator for size as a Decorator[Coffee]
+func WithSize(size Size) Decorator[Coffee] {
+	return func(c Coffee) Coffee {
+		return NewSizeDecorator(c, size)
+	}
+}
+
+// WithDiscount returns the PercentDiscountDecorator for rate as a Decorator[Coffee]
+func WithDiscount(rate BasisPoints) Decorator[Coffee] {
+	return func(c Coffee) Coffee {
+		return NewPercentDiscount(c, rate)
+	}
+}
+
+// WithHappyHour returns the HappyHourDecorator as a Decorator[Coffee]
+func WithHappyHour(window TimeWindow, rate BasisPoints, clock Clock) Decorator[Coffee] {
+	return func(c Coffee) Coffee {
+		return NewHappyHourDecorator(c, window, rate, clock)
+	}
+}
+
+// WithPromo returns the PromoDecorator for p as a Decorator[Coffee]
+func WithPromo(p Promo) Decorator[Coffee] {
+	return func(c Coffee) Coffee {
+		return NewPromoDecorator(c, p)
+	}
+}
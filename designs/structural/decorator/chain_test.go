@@ -0,0 +1,99 @@
+package decorator
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// tag returns a decorator that wraps a string in brackets named after it
+func tag(name string) Decorator[string] {
+	return func(s string) string { return name + "(" + s + ")" }
+}
+
+func TestChainOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		chain *Chain[string]
+		want  string
+		len   int
+	}{
+		{"empty", NewChain[string](), "x", 0},
+		{"one", NewChain(tag("a")), "a(x)", 1},
+		{"first is innermost", NewChain(tag("a"), tag("b"), tag("c")), "c(b(a(x)))", 3},
+		{"nil decorators are skipped", NewChain(nil, tag("a"), nil), "a(x)", 3},
+		{"then appends outside", NewChain(tag("a")).Then(tag("b"), tag("c")), "c(b(a(x)))", 3},
+		{"then on an empty chain", NewChain[string]().Then(tag("a")), "a(x)", 1},
+		{"then with nothing", NewChain(tag("a")).Then(), "a(x)", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.chain.Apply("x"); got != tt.want {
+				t.Errorf("Apply() = %q, want %q", got, tt.want)
+			}
+			if tt.chain.Len() != tt.len {
+				t.Errorf("Len() = %d, want %d", tt.chain.Len(), tt.len)
+			}
+		})
+	}
+
+	if got := Apply("x"); got != "x" {
+		t.Errorf("Apply without decorators = %q", got)
+	}
+}
+
+func TestChainIsImmutable(t *testing.T) {
+	decorators := []Decorator[string]{tag("a"), tag("b")}
+	base := NewChain(decorators...)
+	// changing the slice passed in does not change the chain
+	decorators[0] = tag("z")
+
+	// two chains built from the same base do not share their decorators
+	left, right := base.Then(tag("l")), base.Then(tag("r"))
+	for _, tt := range []struct {
+		chain *Chain[string]
+		want  string
+	}{
+		{base, "b(a(x))"},
+		{left, "l(b(a(x)))"},
+		{right, "r(b(a(x)))"},
+	} {
+		if got := tt.chain.Apply("x"); got != tt.want {
+			t.Errorf("Apply() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestCoffeeChain(t *testing.T) {
+	clock := newFakeClock() // 12:00
+	oat := &AddOn{Name: "oat-milk", Description: "oat milk", Price: NewMoney(70, USD)}
+	var c *CoffeeChain = NewChain(WithMilk, WithAddOn(oat), WithSugar, WithSize(Large), WithDiscount(10*Percent),
+		WithHappyHour(TimeWindow{Start: 11 * time.Hour, End: 14 * time.Hour}, 50*Percent, clock),
+		WithPromo(Promo{Code: "TEN", Amount: NewMoney(10, USD)}))
+	got := c.Apply(&SimpleCoffee{})
+
+	want := NewPromoDecorator(
+		NewHappyHourDecorator(
+			NewPercentDiscount(
+				NewSizeDecorator(NewSugarDecorator(NewAddOnDecorator(NewMilkDecorator(&SimpleCoffee{}), oat)), Large),
+				10*Percent),
+			TimeWindow{Start: 11 * time.Hour, End: 14 * time.Hour}, 50*Percent, clock),
+		Promo{Code: "TEN", Amount: NewMoney(10, USD)})
+	if got.Cost() != want.Cost() || got.Description() != want.Description() {
+		t.Errorf("chain built %q for %v, want %q for %v", got.Description(), got.Cost(), want.Description(), want.Cost())
+	}
+
+	var names []string
+	for _, layer := range Layers(got) {
+		names = append(names, LayerName(layer))
+	}
+	wantNames := []string{"promo", "happy-hour", "discount", "size", "sugar", "oat-milk", "milk", "simple"}
+	if !slices.Equal(names, wantNames) {
+		t.Errorf("layers = %v, want %v", names, wantNames)
+	}
+
+	// each Apply builds a new drink
+	if c.Apply(&SimpleCoffee{}) == got {
+		t.Error("Apply returned the same drink twice")
+	}
+}
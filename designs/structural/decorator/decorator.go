@@ -1,7 +1,16 @@
 package decorator
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
 )
 
 // Let's break down the key components of the Decorator Pattern:
@@ -18,15 +27,45 @@ import (
 
 // Component interface
 type Coffee interface {
-	Cost() float64
+	Cost() Money
 	Description() string
 }
 
-// ConcreteComponent
-type SimpleCoffee struct{}
+// Prices are the prices of the plain coffee and the built-in add-ons in one currency
+type Prices struct {
+	Coffee, Milk, Sugar Money
+}
+
+// DefaultPrices lists the built-in prices per currency. MilkDecorator and SugarDecorator charge in the currency of the
+// drink they wrap, so they work on a SimpleCoffee in any listed currency and on top of catalog drinks alike. Add an
+// entry here, before any drink is priced, to sell in another currency.
+var DefaultPrices = map[Currency]Prices{
+	USD: {Coffee: NewMoney(500, USD), Milk: NewMoney(100, USD), Sugar: NewMoney(50, USD)},
+	EUR: {Coffee: NewMoney(450, EUR), Milk: NewMoney(90, EUR), Sugar: NewMoney(45, EUR)},
+	GBP: {Coffee: NewMoney(400, GBP), Milk: NewMoney(80, GBP), Sugar: NewMoney(40, GBP)},
+	JPY: {Coffee: NewMoney(600, JPY), Milk: NewMoney(120, JPY), Sugar: NewMoney(60, JPY)},
+}
+
+// pricesIn returns the built-in prices in a currency. An amount without a currency is priced in USD. Cost cannot return
+// an error, so a currency without prices panics like a currency mismatch does.
+func pricesIn(c Currency) Prices {
+	if c == "" {
+		c = USD
+	}
+	p, ok := DefaultPrices[c]
+	if !ok {
+		panic(fmt.Errorf("decorator: no built-in prices in %s", c))
+	}
+	return p
+}
+
+// ConcreteComponent. The zero value is priced in USD.
+type SimpleCoffee struct {
+	Currency Currency
+}
 
-func (c *SimpleCoffee) Cost() float64 {
-	return 5.0
+func (c *SimpleCoffee) Cost() Money {
+	return pricesIn(c.Currency).Coffee
 }
 
 func (c *SimpleCoffee) Description() string {
@@ -38,7 +77,7 @@ type CoffeeDecorator struct {
 	coffee Coffee
 }
 
-func (d *CoffeeDecorator) Cost() float64 {
+func (d *CoffeeDecorator) Cost() Money {
 	return d.coffee.Cost()
 }
 
@@ -55,8 +94,9 @@ func NewMilkDecorator(c Coffee) *MilkDecorator {
 	return &MilkDecorator{&CoffeeDecorator{c}}
 }
 
-func (d *MilkDecorator) Cost() float64 {
-	return d.CoffeeDecorator.Cost() + 1.0
+func (d *MilkDecorator) Cost() Money {
+	cost := d.CoffeeDecorator.Cost()
+	return mustAdd(cost, pricesIn(cost.Currency()).Milk)
 }
 
 func (d *MilkDecorator) Description() string {
@@ -71,8 +111,9 @@ func NewSugarDecorator(c Coffee) *SugarDecorator {
 	return &SugarDecorator{&CoffeeDecorator{c}}
 }
 
-func (d *SugarDecorator) Cost() float64 {
-	return d.CoffeeDecorator.Cost() + 0.5
+func (d *SugarDecorator) Cost() Money {
+	cost := d.CoffeeDecorator.Cost()
+	return mustAdd(cost, pricesIn(cost.Currency()).Sugar)
 }
 
 func (d *SugarDecorator) Description() string {
@@ -81,11 +122,124 @@ func (d *SugarDecorator) Description() string {
 
 func main() {
 	var coffee Coffee = &SimpleCoffee{}
-	fmt.Println(coffee.Description(), ":", coffee.Cost()) // Simple coffee : 5
+	fmt.Println(coffee.Description(), ":", coffee.Cost()) // Simple coffee : 5.00 USD
 
 	coffee = NewMilkDecorator(coffee)
-	fmt.Println(coffee.Description(), ":", coffee.Cost()) // Simple coffee, milk : 6
+	fmt.Println(coffee.Description(), ":", coffee.Cost()) // Simple coffee, milk : 6.00 USD
 
 	coffee = NewSugarDecorator(coffee)
-	fmt.Println(coffee.Description(), ":", coffee.Cost()) // Simple coffee, milk, sugar : 6.5
+	fmt.Println(coffee.Description(), ":", coffee.Cost()) // Simple coffee, milk, sugar : 6.50 USD
+
+	// The same drink built from a generic chain of decorators
+	chain := NewChain[Coffee](WithMilk, WithSugar)
+	coffee = chain.Apply(&SimpleCoffee{})
+	fmt.Println(coffee.Description(), ":", coffee.Cost()) // Simple coffee, milk, sugar : 6.50 USD
+
+	// Inspect the layers and take the milk back out
+	fmt.Println(len(Layers(coffee)), Has[*MilkDecorator](coffee)) // 3 true
+	if noMilk, err := Without[*MilkDecorator](coffee); err == nil {
+		fmt.Println(noMilk.Description(), ":", noMilk.Cost()) // Simple coffee, sugar : 5.50 USD
+	}
+
+	// Add-ons loaded from a menu instead of hand-written decorator types
+	menu := []byte(`{"currency": "USD", "addons": [
+		{"name": "oat-milk", "description": "oat milk", "price": "0.75", "max_quantity": 1, "excludes": ["milk"]},
+		{"name": "vanilla-syrup", "description": "vanilla syrup", "price": 0.6, "max_quantity": 3}
+	]}`)
+	if catalog, err := ParseCatalog(menu, FormatJSON); err == nil {
+		latte, err := catalog.Apply(&SimpleCoffee{}, "oat-milk", "vanilla-syrup")
+		if err == nil {
+			fmt.Println(latte.Description(), ":", latte.Cost()) // Simple coffee, oat milk, vanilla syrup : 6.35 USD
+		}
+		_, err = catalog.Apply(NewMilkDecorator(&SimpleCoffee{}), "oat-milk")
+		fmt.Println(err) // decorator: 1 rule violation(s): exclusive: oat-milk at position 2: cannot be combined: milk, oat-milk
+	}
+
+	// Shop-wide rules checked on any chain
+	rules := &Rules{MaxCount: map[string]int{"sugar": 2}, Order: []string{"milk", "sugar"}}
+	tooSweet := Apply[Coffee](&SimpleCoffee{}, WithSugar, WithSugar, WithSugar, WithMilk)
+	fmt.Println(rules.Validate(tooSweet)) // decorator: 2 rule violation(s): max-count: sugar at position 3: ...; order: milk at position 4: ...
+
+	// An itemized receipt instead of a single description and total
+	receipt := Breakdown(tooSweet)
+	fmt.Print(receipt.Text())
+	// Simple coffee  x1  5.00 USD
+	// sugar          x3  1.50 USD
+	// milk           x1  1.00 USD
+	// Total              7.50 USD
+
+	// Pricing wrapped with instrumentation, innermost first
+	latency := NewHistogram()
+	price := NewChain(
+		Breaker[Coffee, Money](NewCircuitBreaker(5, 30*time.Second, SystemClock)),
+		Retry[Coffee, Money](RetryPolicy{Attempts: 3, Initial: 10 * time.Millisecond, Multiplier: 2}),
+		Memoize(NewCache[Coffee, Money](time.Minute, SystemClock)),
+		Timing[Coffee, Money](latency, SystemClock),
+		Logging[Coffee, Money](slog.New(slog.NewTextHandler(os.Stdout, nil)), "price", SystemClock),
+	).Apply(PriceOf)
+	for range 3 {
+		total, _ := price(context.Background(), tooSweet) // logged three times, priced once
+		fmt.Println(total)                                // 7.50 USD
+	}
+	fmt.Println(latency.Snapshot().Count) // 3
+
+	// Save an order and load it back
+	if saved, err := EncodeJSON(tooSweet); err == nil {
+		fmt.Println(string(saved)) // {"version":1,"layers":[{"type":"simple"},{"type":"sugar"},{"type":"sugar"},{"type":"sugar"},{"type":"milk"}]}
+		loaded, _ := DecodeJSON(saved)
+		fmt.Println(loaded.Description(), ":", loaded.Cost()) // Simple coffee, sugar, sugar, sugar, milk : 7.50 USD
+	}
+
+	// One shared base drink, many variations built concurrently
+	base := NewDrink(&SimpleCoffee{}).With(MilkLayer)
+	var wg sync.WaitGroup
+	for i := range 1000 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			variation := base.With(SugarLayer)
+			if i%2 == 0 {
+				variation, _ = variation.Without("milk")
+			}
+			_ = variation.Cost()
+		}()
+	}
+	wg.Wait()
+	fmt.Println(base.Description(), ":", base.Cost()) // Simple coffee, milk : 6.00 USD
+
+	// Multiplicative pricing: a large latte, 10% off, then a promo code
+	promos := NewPromoBook(Promo{Code: "WELCOME", Amount: NewMoney(100, USD)})
+	welcome, _ := promos.Decorator("welcome")
+	order := Apply[Coffee](&SimpleCoffee{},
+		WithMilk,
+		func(c Coffee) Coffee { return NewSizeDecorator(c, Large) },
+		func(c Coffee) Coffee { return NewPercentDiscount(c, 10*Percent) },
+		welcome,
+	)
+	fmt.Println(order.Description(), ":", order.Cost()) // Simple coffee, milk, large, 10% off, promo WELCOME : 7.10 USD
+	fmt.Println(PricingRules.Validate(order))           // <nil>
+
+	// The same pattern on streams: count, compress and encode on the way out, then undo it on the way back in
+	var encoded bytes.Buffer
+	sent, received := &LineCounter{}, &LineCounter{}
+	sum := sha256.New()
+	w := NewWriterStack().CountLines(sent).Checksum(sum).Gzip().Base64().Build(&encoded)
+	io.WriteString(w, "Simple coffee\nmilk\nsugar\n")
+	w.Close()
+	r := NewReaderStack().Base64Decode().Gunzip().CountLines(received).Build(&encoded)
+	decoded, _ := io.ReadAll(r)
+	fmt.Println(string(decoded) == "Simple coffee\nmilk\nsugar\n", sent.Lines(), received.Lines()) // true 3 3
+	fmt.Printf("%x\n", sum.Sum(nil)[:4])
+
+	// Middleware wraps an http.Handler the way MilkDecorator wraps a Coffee
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewMiddlewareChain(
+		Recovery(logger),
+		RequestID(),
+		AccessLog(logger),
+		BasicAuth("coffee", StaticCredentials("barista", "secret")),
+	).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, order.Description())
+	})
+	_ = handler // http.ListenAndServe(":8080", handler) answers "Simple coffee, milk, large, 10% off, promo WELCOME"
 }
@@ -0,0 +1,65 @@
+package decorator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuiltinPricesFollowTheDrink(t *testing.T) {
+	tests := []struct {
+		base Coffee
+		want Money
+	}{
+		{&SimpleCoffee{}, NewMoney(650, USD)},
+		{&SimpleCoffee{Currency: USD}, NewMoney(650, USD)},
+		{&SimpleCoffee{Currency: EUR}, NewMoney(585, EUR)},
+		{&SimpleCoffee{Currency: GBP}, NewMoney(520, GBP)},
+		{&SimpleCoffee{Currency: JPY}, NewMoney(780, JPY)},
+	}
+	for _, tt := range tests {
+		c := NewSugarDecorator(NewMilkDecorator(tt.base))
+		if got := c.Cost(); got != tt.want {
+			t.Errorf("%s: Cost() = %v, want %v", tt.want.Currency(), got, tt.want)
+		}
+	}
+}
+
+func TestBuiltinPricesWithEuroCatalog(t *testing.T) {
+	eur, err := ParseCatalog([]byte(`{"currency":"EUR","addons":[{"name":"oat-milk","description":"oat milk","price":"0.70"}]}`), FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := eur.Apply(NewMilkDecorator(&SimpleCoffee{Currency: EUR}), "oat-milk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = NewSugarDecorator(c)
+	if want := NewMoney(450+90+70+45, EUR); c.Cost() != want {
+		t.Errorf("Cost() = %v, want %v", c.Cost(), want)
+	}
+
+	data, err := EncodeJSON(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeJSON(data)
+	if err != nil || decoded.Cost() != c.Cost() {
+		t.Errorf("round trip: %v, %v", decoded, err)
+	}
+}
+
+func TestBuiltinPricesUnknownCurrency(t *testing.T) {
+	defer func() {
+		if err, _ := recover().(error); err == nil || !strings.Contains(err.Error(), "CHF") {
+			t.Errorf("recovered %v, want an error about CHF", err)
+		}
+	}()
+	(&SimpleCoffee{Currency: "CHF"}).Cost()
+	t.Error("pricing a CHF coffee did not panic")
+}
+
+func TestDecodeUnknownCurrency(t *testing.T) {
+	if _, err := DecodeJSON([]byte(`{"version":1,"layers":[{"type":"simple","data":{"currency":"CHF"}}]}`)); err == nil {
+		t.Error("decoded a coffee in a currency without prices")
+	}
+}
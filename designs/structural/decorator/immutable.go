@@ -0,0 +1,127 @@
+package decorator
+
+// CoffeeDecorator keeps a pointer to the Coffee it wraps but never changes it after construction, and none of the
+// helpers in this package mutate an existing layer (Remove rebuilds the layers it has to change). A finished chain can
+// therefore be read from many goroutines at once.
+//
+// Drink makes that guarantee explicit. It is a persistent linked list of layers: With adds a layer in O(1) by pointing
+// at the existing Drink, and Without rebuilds only the layers above the removed one. Every untouched layer, including
+// the Coffee values built from it, is shared between the old and the new Drink, so thousands of variations of one base
+// drink cost one node per added layer.
+
+// Layer is a named decorator that can be added to a Drink
+type Layer struct {
+	Name string
+	Wrap Decorator[Coffee]
+}
+
+var (
+	// MilkLayer adds a MilkDecorator
+	MilkLayer = Layer{Name: "milk", Wrap: WithMilk}
+	// SugarLayer adds a SugarDecorator
+	SugarLayer = Layer{Name: "sugar", Wrap: WithSugar}
+)
+
+// Layer returns the named add-on as a Layer for a Drink. Like Decorator, it panics when added to a drink in another
+// currency; check the base with CheckCurrency first.
+func (c *Catalog) Layer(name string) (Layer, error) {
+	d, err := c.Decorator(name)
+	if err != nil {
+		return Layer{}, err
+	}
+	return Layer{Name: name, Wrap: d}, nil
+}
+
+// Drink is an immutable Coffee chain. It is safe for concurrent use. Layers and Unwrap see through a Drink to its
+// chain, so Rules, As, Has, Remove, serialization and Breakdown accept a Drink wherever they accept a Coffee.
+type Drink struct {
+	parent *Drink
+	layer  Layer
+	coffee Coffee
+	depth  int
+}
+
+// NewDrink starts a Drink from an undecorated Coffee
+func NewDrink(base Coffee) *Drink {
+	return &Drink{coffee: base}
+}
+
+// With returns a new Drink with the layer added on top. d is not changed and is shared by the result.
+func (d *Drink) With(l Layer) *Drink {
+	return &Drink{parent: d, layer: l, coffee: l.Wrap(d.coffee), depth: d.depth + 1}
+}
+
+// Without returns a new Drink without the outermost layer with the given name. Layers below it are shared with d,
+// layers above it are re-applied. It reports false and returns d if no layer has that name.
+func (d *Drink) Without(name string) (*Drink, bool) {
+	var above []Layer
+	for n := d; n.parent != nil; n = n.parent {
+		if n.layer.Name == name {
+			result := n.parent
+			for i := len(above) - 1; i >= 0; i-- {
+				result = result.With(above[i])
+			}
+			return result, true
+		}
+		above = append(above, n.layer)
+	}
+	return d, false
+}
+
+// Parent returns the Drink without its outermost layer, or nil for an undecorated Drink
+func (d *Drink) Parent() *Drink {
+	return d.parent
+}
+
+// Len returns the number of layers on top of the base Coffee
+func (d *Drink) Len() int {
+	return d.depth
+}
+
+// Layers returns the layers, innermost first
+func (d *Drink) Layers() []Layer {
+	layers := make([]Layer, d.depth)
+	for n := d; n.parent != nil; n = n.parent {
+		layers[n.depth-1] = n.layer
+	}
+	return layers
+}
+
+// Base returns the undecorated Coffee
+func (d *Drink) Base() Coffee {
+	n := d
+	for n.parent != nil {
+		n = n.parent
+	}
+	return n.coffee
+}
+
+// Coffee returns the decorated Coffee. Use it to inspect, validate or encode the chain.
+func (d *Drink) Coffee() Coffee {
+	return d.coffee
+}
+
+func (d *Drink) Cost() Money {
+	return d.coffee.Cost()
+}
+
+func (d *Drink) Description() string {
+	return d.coffee.Description()
+}
+
+// CommonAncestor returns the longest Drink shared by a and b, or nil if they do not share a base
+func CommonAncestor(a, b *Drink) *Drink {
+	for a != nil && b != nil && a.depth > b.depth {
+		a = a.parent
+	}
+	for a != nil && b != nil && b.depth > a.depth {
+		b = b.parent
+	}
+	for a != b {
+		if a == nil || b == nil {
+			return nil
+		}
+		a, b = a.parent, b.parent
+	}
+	return a
+}
@@ -0,0 +1,105 @@
+package decorator
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestDrinkConcurrentVariations(t *testing.T) {
+	root := NewDrink(&SimpleCoffee{})
+	base := root.With(MilkLayer)
+	wantCost, wantDesc := base.Cost(), base.Description()
+
+	var wg sync.WaitGroup
+	for i := range 5000 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sweet := base.With(SugarLayer)
+			if sweet.Parent() != base {
+				t.Error("With did not share the base drink")
+			}
+			if i%2 == 0 {
+				plain, ok := sweet.Without("milk")
+				if !ok || plain.Parent() != root {
+					t.Error("Without did not share the layers below the removed one")
+				}
+				if plain.Description() != "Simple coffee, sugar" {
+					t.Errorf("Description() = %q", plain.Description())
+				}
+			}
+			if CommonAncestor(sweet, base.With(SugarLayer)) != base {
+				t.Error("variations do not share the base drink")
+			}
+			_ = sweet.Cost()
+		}()
+	}
+	wg.Wait()
+
+	if base.Cost() != wantCost || base.Description() != wantDesc {
+		t.Errorf("base changed to %q : %v", base.Description(), base.Cost())
+	}
+	if base.Len() != 1 || base.Parent() != root {
+		t.Errorf("base structure changed: len %d", base.Len())
+	}
+}
+
+func TestDrinkWithoutOutermost(t *testing.T) {
+	d := NewDrink(&SimpleCoffee{}).With(MilkLayer).With(SugarLayer).With(SugarLayer)
+	w, ok := d.Without("sugar")
+	if !ok || w != d.Parent() {
+		t.Error("removing the outermost layer should return its parent")
+	}
+	if _, ok := d.Without("caramel"); ok {
+		t.Error("Without reported removing a missing layer")
+	}
+	if d.Len() != 3 {
+		t.Errorf("d changed: len %d", d.Len())
+	}
+}
+
+func TestDrinkIsTransparent(t *testing.T) {
+	d := NewDrink(&SimpleCoffee{}).With(MilkLayer).With(SugarLayer).With(SugarLayer).With(SugarLayer)
+
+	if got := len(Layers(d)); got != 5 {
+		t.Errorf("Layers(drink) has %d layers, want 5", got)
+	}
+	err := (&Rules{MaxCount: map[string]int{"sugar": 1}}).Validate(d)
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || len(invalid.Violations) != 1 {
+		t.Errorf("Validate(drink) = %v, want one max-count violation", err)
+	}
+	if !Has[*SugarDecorator](d) || Has[*AddOnDecorator](d) {
+		t.Error("Has does not see the drink's layers")
+	}
+	if milk, ok := As[*MilkDecorator](d); !ok || milk.Cost() != NewMilkDecorator(&SimpleCoffee{}).Cost() {
+		t.Error("As did not find the milk layer")
+	}
+	noMilk, err := Without[*MilkDecorator](d)
+	if err != nil || noMilk.Description() != "Simple coffee, sugar, sugar, sugar" {
+		t.Errorf("Without(drink) = %v, %v", noMilk, err)
+	}
+
+	data, err := EncodeJSON(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeJSON(data)
+	if err != nil || decoded.Cost() != d.Cost() || decoded.Description() != d.Description() {
+		t.Errorf("JSON round trip of a drink: %v, %v", decoded, err)
+	}
+	if _, err := EncodeBinary(d); err != nil {
+		t.Errorf("EncodeBinary(drink) = %v", err)
+	}
+
+	if items := Breakdown(d).Items; len(items) != 3 || items[2].Quantity != 3 {
+		t.Errorf("Breakdown(drink) = %+v", items)
+	}
+
+	// a decorator around a drink sees the drink's layers below it
+	wrapped := NewMilkDecorator(d)
+	if got := len(Layers(wrapped)); got != 6 || Unwrap(wrapped) != d.Coffee() {
+		t.Errorf("Layers(milk around drink) has %d layers", got)
+	}
+}
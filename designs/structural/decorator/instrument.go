@@ -0,0 +1,415 @@
+package decorator
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Coffee pricing is a toy, but the same wrapping is how cross-cutting concerns are added to real services. Any component
+// with a single method can be written as a Func, and every decorator in this file is a Decorator[Func[In, Out]], so they
+// stack with Chain like the Coffee decorators do:
+//
+//	price := NewChain(Breaker[Coffee, Money](cb), Retry[Coffee, Money](policy), Logging[Coffee, Money](logger, "price", clock)).Apply(PriceOf)
+
+// Func is a single-method component expressed as a function
+type Func[In, Out any] func(ctx context.Context, in In) (Out, error)
+
+// PriceOf is the Coffee component expressed as a Func
+func PriceOf(ctx context.Context, c Coffee) (Money, error) {
+	if err := ctx.Err(); err != nil {
+		return Money{}, err
+	}
+	return c.Cost(), nil
+}
+
+// Clock tells the time. Decorators that depend on time take a Clock so they can be driven by a fake one.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock is the Clock backed by time.Now
+var SystemClock Clock = systemClock{}
+
+// Logging logs every call with its duration, measured on the clock, and error. A nil clock uses SystemClock.
+func Logging[In, Out any](logger *slog.Logger, name string, clock Clock) Decorator[Func[In, Out]] {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return func(next Func[In, Out]) Func[In, Out] {
+		return func(ctx context.Context, in In) (Out, error) {
+			start := clock.Now()
+			out, err := next(ctx, in)
+			duration := clock.Now().Sub(start)
+			if err != nil {
+				logger.ErrorContext(ctx, "call failed", "name", name, "duration", duration, "error", err)
+			} else {
+				logger.InfoContext(ctx, "call", "name", name, "duration", duration)
+			}
+			return out, err
+		}
+	}
+}
+
+// DefaultLatencyBuckets are the histogram bucket upper bounds used when none are given
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// Histogram counts latencies in buckets. It is safe for concurrent use.
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []time.Duration
+	counts []uint64 // one per bound, plus one for everything above the last bound
+	sum    time.Duration
+}
+
+// HistogramSnapshot is a point-in-time copy of a Histogram
+type HistogramSnapshot struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+// NewHistogram creates a histogram with the given bucket upper bounds, or DefaultLatencyBuckets if none are given
+func NewHistogram(bounds ...time.Duration) *Histogram {
+	if len(bounds) == 0 {
+		bounds = DefaultLatencyBuckets
+	}
+	bounds = append([]time.Duration(nil), bounds...)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	return &Histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+// Observe records one latency
+func (h *Histogram) Observe(d time.Duration) {
+	i := sort.Search(len(h.bounds), func(i int) bool { return d <= h.bounds[i] })
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += d
+	h.mu.Unlock()
+}
+
+// Snapshot returns a copy of the current counts
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := HistogramSnapshot{
+		Bounds: append([]time.Duration(nil), h.bounds...),
+		Counts: append([]uint64(nil), h.counts...),
+		Sum:    h.sum,
+	}
+	for _, c := range h.counts {
+		s.Count += c
+	}
+	return s
+}
+
+// Quantile returns the upper bound of the bucket holding the q-th quantile. Observations above the last bound are
+// reported as the last bound.
+func (s HistogramSnapshot) Quantile(q float64) time.Duration {
+	if s.Count == 0 || len(s.Bounds) == 0 {
+		return 0
+	}
+	rank := uint64(q * float64(s.Count))
+	var seen uint64
+	for i, c := range s.Counts {
+		seen += c
+		if seen > rank && i < len(s.Bounds) {
+			return s.Bounds[i]
+		}
+	}
+	return s.Bounds[len(s.Bounds)-1]
+}
+
+// Timing records the latency of every call in the histogram
+func Timing[In, Out any](h *Histogram, clock Clock) Decorator[Func[In, Out]] {
+	return func(next Func[In, Out]) Func[In, Out] {
+		return func(ctx context.Context, in In) (Out, error) {
+			start := clock.Now()
+			out, err := next(ctx, in)
+			h.Observe(clock.Now().Sub(start))
+			return out, err
+		}
+	}
+}
+
+// RetryPolicy configures Retry
+type RetryPolicy struct {
+	// Attempts is the total number of calls, including the first one
+	Attempts int
+	// Initial is the delay before the first retry
+	Initial time.Duration
+	// Max caps the delay between retries. Zero means no cap.
+	Max time.Duration
+	// Multiplier grows the delay after every retry. Values below 1 are treated as 1.
+	Multiplier float64
+	// Retryable decides whether an error is worth retrying. Nil retries every error except context cancellation.
+	Retryable func(error) bool
+	// Sleep waits between attempts. Nil waits on a timer and stops early when the context is done.
+	Sleep func(ctx context.Context, d time.Duration) error
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+func (p RetryPolicy) sleep(ctx context.Context, d time.Duration) error {
+	if p.Sleep != nil {
+		return p.Sleep(ctx, d)
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Retry calls the component again with exponential backoff when it fails
+func Retry[In, Out any](p RetryPolicy) Decorator[Func[In, Out]] {
+	return func(next Func[In, Out]) Func[In, Out] {
+		return func(ctx context.Context, in In) (Out, error) {
+			delay := p.Initial
+			for attempt := 1; ; attempt++ {
+				out, err := next(ctx, in)
+				if err == nil || attempt >= p.Attempts || !p.retryable(err) {
+					return out, err
+				}
+				if serr := p.sleep(ctx, delay); serr != nil {
+					return out, errors.Join(err, serr)
+				}
+				delay = time.Duration(float64(delay) * max(p.Multiplier, 1))
+				if p.Max > 0 && delay > p.Max {
+					delay = p.Max
+				}
+			}
+		}
+	}
+}
+
+// Cache stores results by input. Entries older than the TTL are ignored and swept out as new ones are stored, so a
+// cache with a TTL only holds what was stored in roughly the last two TTLs; a zero TTL keeps entries forever.
+// It is safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	clock     Clock
+	entries   map[K]cacheEntry[V]
+	lastSweep time.Time
+}
+
+type cacheEntry[V any] struct {
+	value  V
+	stored time.Time
+}
+
+// NewCache creates an empty cache
+func NewCache[K comparable, V any](ttl time.Duration, clock Clock) *Cache[K, V] {
+	return &Cache[K, V]{ttl: ttl, clock: clock, entries: make(map[K]cacheEntry[V])}
+}
+
+// Get returns the cached value for the key if it has not expired
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if ok && c.expired(e, c.clock.Now()) {
+		delete(c.entries, key)
+		ok = false
+	}
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+func (c *Cache[K, V]) expired(e cacheEntry[V], now time.Time) bool {
+	return c.ttl > 0 && now.Sub(e.stored) > c.ttl
+}
+
+// Set stores a value for the key. At most once per TTL it also removes every expired entry, which keeps the cost of
+// sweeping constant per stored value.
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock.Now()
+	if c.ttl > 0 && now.Sub(c.lastSweep) >= c.ttl {
+		for k, e := range c.entries {
+			if c.expired(e, now) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastSweep = now
+	}
+	c.entries[key] = cacheEntry[V]{value: value, stored: now}
+}
+
+// Delete removes the key
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
+
+// Len returns the number of stored entries, including expired ones that have not been swept yet
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Memoize serves repeated inputs from the cache. Failed calls are not cached. An interface input whose dynamic value
+// cannot be a map key, such as a struct holding a slice, is passed straight through instead of panicking.
+func Memoize[In comparable, Out any](cache *Cache[In, Out]) Decorator[Func[In, Out]] {
+	return func(next Func[In, Out]) Func[In, Out] {
+		return func(ctx context.Context, in In) (Out, error) {
+			if v := reflect.ValueOf(&in).Elem(); !v.Comparable() {
+				return next(ctx, in)
+			}
+			if out, ok := cache.Get(in); ok {
+				return out, nil
+			}
+			out, err := next(ctx, in)
+			if err == nil {
+				cache.Set(in, out)
+			}
+			return out, err
+		}
+	}
+}
+
+// ErrCircuitOpen is returned without calling the component while the circuit breaker is open
+var ErrCircuitOpen = errors.New("decorator: circuit breaker is open")
+
+// BreakerState is the state of a CircuitBreaker
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects every call until the cooldown has passed
+	BreakerOpen
+	// BreakerHalfOpen lets a single trial call through to decide whether to close again
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker opens after a number of consecutive failures and stays open for a cooldown period.
+// It is safe for concurrent use and can be shared by several decorated components.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	clock     Clock
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	trial     bool
+}
+
+// NewCircuitBreaker creates a closed breaker that opens after threshold consecutive failures
+func NewCircuitBreaker(threshold int, cooldown time.Duration, clock Clock) *CircuitBreaker {
+	return &CircuitBreaker{threshold: max(threshold, 1), cooldown: cooldown, clock: clock}
+}
+
+// State returns the current state of the breaker
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+	return b.state
+}
+
+// refresh moves an open breaker to half-open once the cooldown has passed
+func (b *CircuitBreaker) refresh() {
+	if b.state == BreakerOpen && b.clock.Now().Sub(b.openedAt) >= b.cooldown {
+		b.state = BreakerHalfOpen
+		b.trial = false
+	}
+}
+
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+	switch b.state {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+	}
+	return true
+}
+
+// record counts the outcome of a call. A call that was cancelled or ran out of time says nothing about the component,
+// so it neither closes nor opens the breaker; it only gives up a half-open breaker's trial.
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		b.trial = false
+		return
+	}
+	if err == nil {
+		b.state, b.failures, b.trial = BreakerClosed, 0, false
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state, b.openedAt, b.trial = BreakerOpen, b.clock.Now(), false
+	}
+}
+
+// Breaker guards the component with the circuit breaker
+func Breaker[In, Out any](b *CircuitBreaker) Decorator[Func[In, Out]] {
+	return func(next Func[In, Out]) Func[In, Out] {
+		return func(ctx context.Context, in In) (Out, error) {
+			if !b.allow() {
+				var zero Out
+				return zero, ErrCircuitOpen
+			}
+			out, err := next(ctx, in)
+			b.record(err)
+			return out, err
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package decorator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheExpiry(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewCache[string, int](time.Minute, clock)
+	cache.Set("a", 1)
+	clock.Advance(time.Minute)
+	if v, ok := cache.Get("a"); !ok || v != 1 {
+		t.Errorf("Get at the TTL = %v, %v", v, ok)
+	}
+	clock.Advance(time.Second)
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Get after the TTL found the entry")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Len() = %d after an expired Get, want 0", cache.Len())
+	}
+
+	// storing new keys sweeps out the old ones
+	for i := range 100 {
+		cache.Set(strings.Repeat("k", i+1), i)
+	}
+	clock.Advance(2 * time.Minute)
+	cache.Set("fresh", 0)
+	if cache.Len() != 1 {
+		t.Errorf("Len() = %d after a sweep, want 1", cache.Len())
+	}
+
+	forever := NewCache[string, int](0, clock)
+	forever.Set("a", 1)
+	clock.Advance(24 * time.Hour)
+	forever.Set("b", 2)
+	if _, ok := forever.Get("a"); !ok || forever.Len() != 2 {
+		t.Errorf("a zero TTL dropped entries: Len() = %d", forever.Len())
+	}
+}
+
+// listedCoffee is a Coffee that cannot be a map key
+type listedCoffee []string
+
+func (c listedCoffee) Cost() Money         { return NewMoney(int64(100*len(c)), USD) }
+func (c listedCoffee) Description() string { return strings.Join(c, ", ") }
+
+func TestMemoize(t *testing.T) {
+	calls := 0
+	fail := false
+	price := Memoize(NewCache[Coffee, Money](time.Minute, newFakeClock()))(func(ctx context.Context, c Coffee) (Money, error) {
+		calls++
+		if fail {
+			return Money{}, errors.New("till closed")
+		}
+		return c.Cost(), nil
+	})
+
+	c := NewMilkDecorator(&SimpleCoffee{})
+	fail = true
+	if _, err := price(t.Context(), c); err == nil {
+		t.Fatal("the failing call succeeded")
+	}
+	fail = false
+	for range 3 {
+		if got, err := price(t.Context(), c); err != nil || got != c.Cost() {
+			t.Errorf("price = %v, %v", got, err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("%d calls, want 2: the failure is not cached and the success is", calls)
+	}
+
+	calls = 0
+	for range 2 {
+		if got, err := price(t.Context(), listedCoffee{"a", "b"}); err != nil || got != NewMoney(200, USD) {
+			t.Errorf("price = %v, %v", got, err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("%d calls for an uncacheable input, want 2", calls)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	errFlaky := errors.New("flaky")
+	var delays []time.Duration
+	policy := RetryPolicy{
+		Attempts:   5,
+		Initial:    10 * time.Millisecond,
+		Max:        30 * time.Millisecond,
+		Multiplier: 2,
+		Sleep: func(ctx context.Context, d time.Duration) error {
+			delays = append(delays, d)
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		calls     int
+		delays    []time.Duration
+		succeeded bool
+	}{
+		{"first try", 0, errFlaky, 1, nil, true},
+		{"after two failures", 2, errFlaky, 3, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}, true},
+		{"out of attempts", 10, errFlaky, 5, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond}, false},
+		{"not retryable", 10, context.Canceled, 1, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delays = nil
+			calls := 0
+			f := Retry[int, int](policy)(func(ctx context.Context, in int) (int, error) {
+				calls++
+				if calls <= tt.failures {
+					return 0, tt.err
+				}
+				return in * 2, nil
+			})
+			out, err := f(t.Context(), 21)
+			if (err == nil) != tt.succeeded || (err == nil && out != 42) {
+				t.Errorf("f = %v, %v", out, err)
+			}
+			if err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+			if calls != tt.calls || len(delays) != len(tt.delays) {
+				t.Fatalf("%d calls with delays %v, want %d with %v", calls, delays, tt.calls, tt.delays)
+			}
+			for i := range delays {
+				if delays[i] != tt.delays[i] {
+					t.Errorf("delays = %v, want %v", delays, tt.delays)
+					break
+				}
+			}
+		})
+	}
+
+	// the default sleep gives up when the context is done and reports both errors
+	ctx, cancel := context.WithCancel(t.Context())
+	f := Retry[int, int](RetryPolicy{Attempts: 3, Initial: time.Hour})(func(ctx context.Context, in int) (int, error) {
+		cancel()
+		return 0, errFlaky
+	})
+	if _, err := f(ctx, 1); !errors.Is(err, errFlaky) || !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want both the failure and the cancellation", err)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	clock := newFakeClock()
+	b := NewCircuitBreaker(2, time.Minute, clock)
+	var result error
+	calls := 0
+	f := Breaker[int, int](b)(func(ctx context.Context, in int) (int, error) {
+		calls++
+		return in, result
+	})
+	errDown := errors.New("down")
+	step := func(name string, err error, wantErr error, wantState BreakerState) {
+		t.Helper()
+		result = err
+		if _, got := f(t.Context(), 1); !errors.Is(got, wantErr) {
+			t.Errorf("%s: err = %v, want %v", name, got, wantErr)
+		}
+		if b.State() != wantState {
+			t.Errorf("%s: state = %v, want %v", name, b.State(), wantState)
+		}
+	}
+
+	step("first failure", errDown, errDown, BreakerClosed)
+	step("success resets the count", nil, nil, BreakerClosed)
+	step("failure", errDown, errDown, BreakerClosed)
+	step("cancellation is not a failure", context.Canceled, context.Canceled, BreakerClosed)
+	step("timeout is not a failure", context.DeadlineExceeded, context.DeadlineExceeded, BreakerClosed)
+	step("second failure opens", errDown, errDown, BreakerOpen)
+
+	calls = 0
+	step("open rejects", nil, ErrCircuitOpen, BreakerOpen)
+	if calls != 0 {
+		t.Errorf("an open breaker called the component %d times", calls)
+	}
+
+	clock.Advance(time.Minute)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state after the cooldown = %v", b.State())
+	}
+	step("failed trial reopens", errDown, errDown, BreakerOpen)
+	clock.Advance(time.Minute)
+	step("cancelled trial stays half-open", context.Canceled, context.Canceled, BreakerHalfOpen)
+	step("successful trial closes", nil, nil, BreakerClosed)
+
+	// a half-open breaker lets one trial through at a time
+	step("failure after closing", errDown, errDown, BreakerClosed)
+	step("second failure reopens", errDown, errDown, BreakerOpen)
+	clock.Advance(time.Minute)
+	if !b.allow() || b.allow() {
+		t.Error("a half-open breaker let two trials through")
+	}
+}
+
+func TestLogging(t *testing.T) {
+	clock := newFakeClock()
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	f := Logging[int, int](logger, "double", clock)(func(ctx context.Context, in int) (int, error) {
+		clock.Advance(250 * time.Millisecond)
+		if in < 0 {
+			return 0, errors.New("negative")
+		}
+		return in * 2, nil
+	})
+
+	if out, err := f(t.Context(), 2); out != 4 || err != nil {
+		t.Errorf("f(2) = %v, %v", out, err)
+	}
+	line := logs.String()
+	for _, want := range []string{"level=INFO", "msg=call", "name=double", "duration=250ms"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("log %q does not contain %q", line, want)
+		}
+	}
+
+	logs.Reset()
+	if _, err := f(t.Context(), -1); err == nil {
+		t.Error("f(-1) succeeded")
+	}
+	line = logs.String()
+	for _, want := range []string{"level=ERROR", `msg="call failed"`, "duration=250ms", "error=negative"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("log %q does not contain %q", line, want)
+		}
+	}
+}
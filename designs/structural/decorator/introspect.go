@@ -0,0 +1,159 @@
+package decorator
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Introspection works the same way errors.Unwrap, errors.Is and errors.As do for wrapped errors: every decorator exposes the
+// Coffee it wraps through an Unwrap method, and the helpers below walk that chain from the outermost layer inwards.
+
+// Wrapper is implemented by every Coffee that decorates another Coffee
+type Wrapper interface {
+	Unwrap() Coffee
+}
+
+// Rewrapper is implemented by decorators that can rebuild themselves around a different inner Coffee.
+// It is what lets a layer be removed from the middle of a chain without touching the other layers.
+type Rewrapper interface {
+	Rewrap(inner Coffee) Coffee
+}
+
+var (
+	// ErrLayerNotFound is returned when no layer of a chain matches
+	ErrLayerNotFound = errors.New("decorator: layer not found")
+	// ErrBaseLayer is returned when asked to remove the undecorated base Coffee
+	ErrBaseLayer = errors.New("decorator: cannot remove the base coffee")
+)
+
+// NotRewrappableError is returned when a layer sits above a removed layer but cannot be rebuilt around a new inner Coffee
+type NotRewrappableError struct {
+	Layer Coffee
+}
+
+func (e *NotRewrappableError) Error() string {
+	return fmt.Sprintf("decorator: layer %T does not implement Rewrapper", e.Layer)
+}
+
+// Named is implemented by layers that have a stable name, such as "milk" or a catalog add-on name
+type Named interface {
+	Name() string
+}
+
+// LayerName returns the name of a single layer. Layers that do not implement Named are named after their Go type.
+func LayerName(c Coffee) string {
+	if n, ok := c.(Named); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", c)
+}
+
+// Unwrap returns the Coffee wrapped by this decorator
+func (d *CoffeeDecorator) Unwrap() Coffee {
+	return d.coffee
+}
+
+// Name returns "simple"
+func (c *SimpleCoffee) Name() string {
+	return "simple"
+}
+
+// Name returns "milk"
+func (d *MilkDecorator) Name() string {
+	return "milk"
+}
+
+// Name returns "sugar"
+func (d *SugarDecorator) Name() string {
+	return "sugar"
+}
+
+// Rewrap returns a new MilkDecorator around the given Coffee
+func (d *MilkDecorator) Rewrap(inner Coffee) Coffee {
+	return NewMilkDecorator(inner)
+}
+
+// Rewrap returns a new SugarDecorator around the given Coffee
+func (d *SugarDecorator) Rewrap(inner Coffee) Coffee {
+	return NewSugarDecorator(inner)
+}
+
+// chainOf sees through a Drink to the chain it holds, so that every helper walking layers treats a Drink like its
+// Coffee
+func chainOf(c Coffee) Coffee {
+	for {
+		d, ok := c.(*Drink)
+		if !ok {
+			return c
+		}
+		c = d.Coffee()
+	}
+}
+
+// Unwrap returns the Coffee wrapped by c, or nil if c is not a decorator. A Drink is unwrapped like the chain it holds.
+func Unwrap(c Coffee) Coffee {
+	w, ok := chainOf(c).(Wrapper)
+	if !ok {
+		return nil
+	}
+	return chainOf(w.Unwrap())
+}
+
+// Layers returns every layer of the chain, outermost first. The last element is the base Coffee. A Drink is not a layer
+// itself; its chain is returned instead.
+func Layers(c Coffee) []Coffee {
+	var layers []Coffee
+	for c = chainOf(c); c != nil; c = Unwrap(c) {
+		layers = append(layers, c)
+	}
+	return layers
+}
+
+// As finds the outermost layer of type D in the chain
+func As[D Coffee](c Coffee) (D, bool) {
+	for _, layer := range Layers(c) {
+		if d, ok := layer.(D); ok {
+			return d, true
+		}
+	}
+	var zero D
+	return zero, false
+}
+
+// Has reports whether any layer of the chain has type D
+func Has[D Coffee](c Coffee) bool {
+	_, ok := As[D](c)
+	return ok
+}
+
+// Remove returns a new chain without the outermost layer matching match. Layers inside the removed one are reused as-is,
+// layers outside it are rebuilt with Rewrap, and the original chain is left untouched.
+func Remove(c Coffee, match func(Coffee) bool) (Coffee, error) {
+	layers := Layers(c)
+	for i, layer := range layers {
+		if !match(layer) {
+			continue
+		}
+		if i == len(layers)-1 {
+			return nil, ErrBaseLayer
+		}
+		result := Unwrap(layer)
+		for j := i - 1; j >= 0; j-- {
+			rw, ok := layers[j].(Rewrapper)
+			if !ok {
+				return nil, &NotRewrappableError{Layer: layers[j]}
+			}
+			result = rw.Rewrap(result)
+		}
+		return result, nil
+	}
+	return nil, ErrLayerNotFound
+}
+
+// Without returns a new chain without the outermost layer of type D
+func Without[D Coffee](c Coffee) (Coffee, error) {
+	return Remove(c, func(layer Coffee) bool {
+		_, ok := layer.(D)
+		return ok
+	})
+}
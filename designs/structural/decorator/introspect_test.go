@@ -0,0 +1,142 @@
+package decorator
+
+import (
+	"errors"
+	"testing"
+)
+
+// frozenDecorator is a layer that cannot be rebuilt around another Coffee
+type frozenDecorator struct {
+	CoffeeDecorator
+}
+
+// introspectChain returns sugar(oat-milk(milk(simple))) and its layers, outermost first
+func introspectChain() (Coffee, []Coffee) {
+	c := NewSugarDecorator(NewAddOnDecorator(NewMilkDecorator(&SimpleCoffee{}),
+		&AddOn{Name: "oat-milk", Description: "oat milk", Price: NewMoney(70, USD)}))
+	return c, Layers(c)
+}
+
+func TestRemove(t *testing.T) {
+	named := func(name string) func(Coffee) bool {
+		return func(c Coffee) bool { return LayerName(c) == name }
+	}
+	tests := []struct {
+		name  string
+		match func(Coffee) bool
+		want  string
+		err   error
+		// reused is true when the result is the layer below the removed one, with nothing rebuilt above it
+		reused bool
+	}{
+		{"outer", named("sugar"), "Simple coffee, milk, oat milk", nil, true},
+		{"middle", named("oat-milk"), "Simple coffee, milk, sugar", nil, false},
+		{"inner", named("milk"), "Simple coffee, oat milk, sugar", nil, false},
+		{"absent", named("caramel"), "", ErrLayerNotFound, false},
+		{"base", named("simple"), "", ErrBaseLayer, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, layers := introspectChain()
+			got, err := Remove(c, tt.match)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				if got != nil {
+					t.Errorf("Remove returned %v with an error", got)
+				}
+				return
+			}
+			if got.Description() != tt.want {
+				t.Errorf("Description() = %q, want %q", got.Description(), tt.want)
+			}
+			if c.Description() != "Simple coffee, milk, oat milk, sugar" {
+				t.Errorf("the original chain changed to %q", c.Description())
+			}
+			// the layers below the removed one are shared, the ones above are new
+			gotLayers := Layers(got)
+			if gotLayers[len(gotLayers)-1] != layers[len(layers)-1] {
+				t.Error("the base was not shared")
+			}
+			if tt.reused && got != layers[1] {
+				t.Error("removing the outermost layer did not return the layer below it")
+			}
+			if !tt.reused && gotLayers[0] == layers[0] {
+				t.Error("a layer above the removed one was reused")
+			}
+		})
+	}
+}
+
+func TestWithout(t *testing.T) {
+	c, layers := introspectChain()
+	tests := []struct {
+		name string
+		got  func() (Coffee, error)
+		want string
+		err  error
+	}{
+		{"outer", func() (Coffee, error) { return Without[*SugarDecorator](c) }, "Simple coffee, milk, oat milk", nil},
+		{"inner", func() (Coffee, error) { return Without[*MilkDecorator](c) }, "Simple coffee, oat milk, sugar", nil},
+		{"absent", func() (Coffee, error) { return Without[*SizeDecorator](c) }, "", ErrLayerNotFound},
+		{"base", func() (Coffee, error) { return Without[*SimpleCoffee](c) }, "", ErrBaseLayer},
+		{"outermost of two", func() (Coffee, error) { return Without[*SugarDecorator](NewSugarDecorator(c)) }, "Simple coffee, milk, oat milk, sugar", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.got()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err == nil && got.Description() != tt.want {
+				t.Errorf("Description() = %q, want %q", got.Description(), tt.want)
+			}
+		})
+	}
+	if len(Layers(c)) != len(layers) {
+		t.Error("Without changed the original chain")
+	}
+}
+
+func TestRemoveNotRewrappable(t *testing.T) {
+	c, _ := introspectChain()
+	frozen := &frozenDecorator{CoffeeDecorator{coffee: c}}
+
+	_, err := Without[*MilkDecorator](frozen)
+	var notRewrappable *NotRewrappableError
+	if !errors.As(err, &notRewrappable) || notRewrappable.Layer != Coffee(frozen) {
+		t.Errorf("err = %v, want a *NotRewrappableError for the frozen layer", err)
+	}
+	// the frozen layer itself can go, since nothing above it needs rebuilding
+	if got, err := Without[*frozenDecorator](frozen); err != nil || got != c {
+		t.Errorf("Without(frozen) = %v, %v", got, err)
+	}
+}
+
+func TestAs(t *testing.T) {
+	c, layers := introspectChain()
+	if sugar, ok := As[*SugarDecorator](c); !ok || sugar != layers[0] {
+		t.Errorf("As[*SugarDecorator] = %v, %v", sugar, ok)
+	}
+	if milk, ok := As[*MilkDecorator](c); !ok || milk != layers[2] {
+		t.Errorf("As[*MilkDecorator] = %v, %v", milk, ok)
+	}
+	if base, ok := As[*SimpleCoffee](c); !ok || base != layers[3] {
+		t.Errorf("As[*SimpleCoffee] = %v, %v", base, ok)
+	}
+	if size, ok := As[*SizeDecorator](c); ok || size != nil {
+		t.Errorf("As[*SizeDecorator] = %v, %v", size, ok)
+	}
+	// the outermost match wins
+	outer := NewSugarDecorator(c)
+	if sugar, ok := As[*SugarDecorator](outer); !ok || sugar != outer {
+		t.Errorf("As[*SugarDecorator] on two sugars = %v, %v", sugar, ok)
+	}
+	if !Has[*MilkDecorator](c) || Has[*SizeDecorator](c) {
+		t.Error("Has disagrees with As")
+	}
+	if _, ok := As[*MilkDecorator](nil); ok {
+		t.Error("As found a layer in a nil chain")
+	}
+}
@@ -0,0 +1,348 @@
+package decorator
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTP middleware is the decorator pattern with http.Handler as the Component: every Middleware takes a handler and
+// returns one that does some work before and after calling it, exactly like MilkDecorator wraps a Coffee.
+//
+// Unlike Chain, a MiddlewareChain lists its middlewares from the outside in, which is how request handling reads:
+//
+//	NewMiddlewareChain(Recovery(logger), RequestID(), AccessLog(logger)).Then(mux)
+//
+// runs Recovery first, then RequestID, then AccessLog, then mux.
+
+// Middleware decorates an http.Handler
+type Middleware = Decorator[http.Handler]
+
+// MiddlewareChain is an ordered list of middlewares, outermost first
+type MiddlewareChain struct {
+	middlewares []Middleware
+}
+
+// NewMiddlewareChain creates a chain from the given middlewares, outermost first
+func NewMiddlewareChain(middlewares ...Middleware) *MiddlewareChain {
+	return &MiddlewareChain{middlewares: slices.Clone(middlewares)}
+}
+
+// Append returns a new chain with the given middlewares added inside the existing ones
+func (c *MiddlewareChain) Append(middlewares ...Middleware) *MiddlewareChain {
+	return &MiddlewareChain{middlewares: slices.Concat(c.middlewares, middlewares)}
+}
+
+// Then wraps the handler with every middleware in the chain. A nil handler means http.DefaultServeMux.
+func (c *MiddlewareChain) Then(h http.Handler) http.Handler {
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+	inner := slices.Clone(c.middlewares)
+	slices.Reverse(inner)
+	return NewChain(inner...).Apply(h)
+}
+
+// ThenFunc wraps a handler function with every middleware in the chain
+func (c *MiddlewareChain) ThenFunc(f http.HandlerFunc) http.Handler {
+	return c.Then(f)
+}
+
+// RequestIDHeader is the header read and written by RequestID
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestIDFrom returns the request ID stored by RequestID, or ""
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID makes sure every request has an ID. An incoming X-Request-ID header is kept, otherwise a random one is
+// generated. The ID is echoed in the response header and stored in the request context.
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if id == "" {
+				id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+		})
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Recovery turns a panic in the handler into a 500 response and logs it. http.ErrAbortHandler is re-panicked so the
+// server can abort the response as intended.
+func Recovery(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				logger.ErrorContext(r.Context(), "panic serving request",
+					"method", r.Method, "path", r.URL.Path, "panic", fmt.Sprint(rec), "request_id", RequestIDFrom(r.Context()))
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// statusRecorder remembers the status code and body size written through it
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(p)
+	s.bytes += n
+	return n, err
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// AccessLog logs one line per request with its status, size and duration
+func AccessLog(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			logger.InfoContext(r.Context(), "request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", rec.status,
+				"bytes", rec.bytes,
+				"duration", time.Since(start),
+				"request_id", RequestIDFrom(r.Context()))
+		})
+	}
+}
+
+// gzipResponseWriter compresses the body written through it. The status is held back and the gzip stream is only
+// started on the first non-empty write, so a handler that panics first leaves the response untouched for Recovery,
+// and responses without a body are never encoded. Informational, 204 and 304 responses, and responses the handler
+// encoded itself, pass through unchanged.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	status      int
+	wroteHeader bool
+	passthrough bool
+}
+
+func bodyless(code int) bool {
+	return code == http.StatusNoContent || code == http.StatusNotModified
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	switch {
+	case g.wroteHeader || g.status != 0:
+		return
+	case code < 200:
+		g.ResponseWriter.WriteHeader(code)
+	case bodyless(code) || g.Header().Get("Content-Encoding") != "":
+		g.passthrough = true
+		g.sendHeader(code)
+	default:
+		g.status = code
+	}
+}
+
+func (g *gzipResponseWriter) sendHeader(code int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+	if code != 0 {
+		g.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if g.passthrough {
+		return g.ResponseWriter.Write(p)
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if g.gz == nil {
+		if g.Header().Get("Content-Encoding") != "" {
+			g.passthrough = true
+			g.sendHeader(g.status)
+			return g.ResponseWriter.Write(p)
+		}
+		g.Header().Set("Content-Encoding", "gzip")
+		g.Header().Del("Content-Length")
+		g.sendHeader(g.status)
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+	}
+	return g.gz.Write(p)
+}
+
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+// close finishes the gzip stream, or sends the held back status of a response without a body
+func (g *gzipResponseWriter) close() error {
+	if g.gz != nil {
+		return g.gz.Close()
+	}
+	g.sendHeader(g.status)
+	return nil
+}
+
+// Gzip compresses responses for clients that accept gzip
+func Gzip() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r) || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			gw := &gzipResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(gw, r)
+			// not deferred: after a panic the stream must not be finished with a 200
+			gw.close()
+		})
+	}
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") && strings.TrimSpace(params) != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// CORSOptions configures CORS
+type CORSOptions struct {
+	// AllowedOrigins lists the allowed origins. "*" allows any origin.
+	AllowedOrigins []string
+	// AllowedMethods defaults to GET, POST and HEAD
+	AllowedMethods []string
+	AllowedHeaders []string
+	// AllowCredentials lets browsers send cookies. The request's origin is echoed instead of "*" when set.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+func (o CORSOptions) allows(origin string) bool {
+	return slices.Contains(o.AllowedOrigins, "*") || slices.Contains(o.AllowedOrigins, origin)
+}
+
+// CORS adds cross-origin headers for allowed origins and answers preflight requests itself
+func CORS(opts CORSOptions) Middleware {
+	if len(opts.AllowedMethods) == 0 {
+		opts.AllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodHead}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			w.Header().Add("Vary", "Origin")
+			if origin == "" || !opts.allows(origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			if slices.Contains(opts.AllowedOrigins, "*") && !opts.AllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if opts.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			h.Set("Access-Control-Allow-Methods", strings.Join(opts.AllowedMethods, ", "))
+			if len(opts.AllowedHeaders) > 0 {
+				h.Set("Access-Control-Allow-Headers", strings.Join(opts.AllowedHeaders, ", "))
+			}
+			if opts.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// Timeout cancels the request context after d and answers 503 if the handler has not finished by then
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.TimeoutHandler(next, d, "request timed out")
+	}
+}
+
+// BasicAuth rejects requests whose basic auth credentials are not accepted by check
+func BasicAuth(realm string, check func(user, password string) bool) Middleware {
+	challenge := fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", realm)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, password, ok := r.BasicAuth()
+			if !ok || !check(user, password) {
+				w.Header().Set("WWW-Authenticate", challenge)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// StaticCredentials returns a BasicAuth check for a single user, compared in constant time
+func StaticCredentials(user, password string) func(string, string) bool {
+	return func(u, p string) bool {
+		userOK := subtle.ConstantTimeCompare([]byte(u), []byte(user)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1
+		return userOK && passOK
+	}
+}
@@ -0,0 +1,254 @@
+package decorator
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFrom(r.Context())
+	}))
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	if id := rec.Header().Get(RequestIDHeader); id == "" || id != seen {
+		t.Errorf("generated ID: header %q, context %q", id, seen)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "abc123")
+	rec = serve(h, req)
+	if id := rec.Header().Get(RequestIDHeader); id != "abc123" || seen != "abc123" {
+		t.Errorf("incoming ID not kept: header %q, context %q", id, seen)
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	var logs bytes.Buffer
+	h := NewMiddlewareChain(Recovery(slog.New(slog.NewTextHandler(&logs, nil))), Gzip()).
+		ThenFunc(func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+
+	req := httptest.NewRequest(http.MethodGet, "/order", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := serve(h, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if ce := rec.Header().Get("Content-Encoding"); ce != "" {
+		t.Errorf("Content-Encoding = %q on the error response", ce)
+	}
+	if !strings.Contains(logs.String(), "panic=boom") || !strings.Contains(logs.String(), "path=/order") {
+		t.Errorf("log = %q", logs.String())
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var logs bytes.Buffer
+	h := AccessLog(slog.New(slog.NewTextHandler(&logs, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, "short and stout")
+	}))
+	serve(h, httptest.NewRequest(http.MethodPost, "/brew", nil))
+	for _, want := range []string{"method=POST", "path=/brew", "status=418", "bytes=15"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("log %q is missing %q", logs.String(), want)
+		}
+	}
+}
+
+func TestGzip(t *testing.T) {
+	body := strings.Repeat("Simple coffee, milk\n", 100)
+	tests := []struct {
+		name     string
+		accept   string
+		handler  http.HandlerFunc
+		status   int
+		encoding string
+		body     string
+	}{
+		{
+			name:     "compressed",
+			accept:   "gzip, deflate",
+			handler:  func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, body) },
+			status:   http.StatusOK,
+			encoding: "gzip",
+			body:     body,
+		},
+		{
+			name:   "not accepted",
+			accept: "gzip;q=0",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, body)
+			},
+			status: http.StatusOK,
+			body:   body,
+		},
+		{
+			name:   "no content",
+			accept: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "not modified",
+			accept: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotModified)
+			},
+			status: http.StatusNotModified,
+		},
+		{
+			name:   "empty body",
+			accept: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				w.Write(nil)
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name:   "already encoded",
+			accept: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "br")
+				io.WriteString(w, "brotli bytes")
+			},
+			status:   http.StatusOK,
+			encoding: "br",
+			body:     "brotli bytes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", tt.accept)
+			rec := serve(Gzip()(tt.handler), req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if ce := rec.Header().Get("Content-Encoding"); ce != tt.encoding {
+				t.Errorf("Content-Encoding = %q, want %q", ce, tt.encoding)
+			}
+			got := rec.Body.Bytes()
+			if tt.encoding == "gzip" {
+				zr, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, err = io.ReadAll(zr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if string(got) != tt.body {
+				t.Errorf("body = %q, want %q", got, tt.body)
+			}
+			if rec.Header().Get("Vary") != "Accept-Encoding" {
+				t.Errorf("Vary = %q", rec.Header().Get("Vary"))
+			}
+		})
+	}
+}
+
+func TestGzipOverHTTP(t *testing.T) {
+	body := strings.Repeat("espresso ", 500)
+	srv := httptest.NewServer(Gzip()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	})))
+	defer srv.Close()
+
+	// the transport asks for gzip and decompresses transparently
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	got, _ := io.ReadAll(resp.Body)
+	if !resp.Uncompressed || string(got) != body {
+		t.Errorf("uncompressed %v, got %d bytes, want %d", resp.Uncompressed, len(got), len(body))
+	}
+}
+
+func TestCORS(t *testing.T) {
+	h := CORS(CORSOptions{
+		AllowedOrigins: []string{"https://menu.example"},
+		AllowedHeaders: []string{"Content-Type"},
+		MaxAge:         10 * time.Minute,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "handler")
+	}))
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/", nil)
+	preflight.Header.Set("Origin", "https://menu.example")
+	preflight.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec := serve(h, preflight)
+	if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+		t.Errorf("preflight: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":  "https://menu.example",
+		"Access-Control-Allow-Methods": "GET, POST, HEAD",
+		"Access-Control-Allow-Headers": "Content-Type",
+		"Access-Control-Max-Age":       "600",
+	}
+	for k, v := range want {
+		if got := rec.Header().Get(k); got != v {
+			t.Errorf("preflight %s = %q, want %q", k, got, v)
+		}
+	}
+
+	other := httptest.NewRequest(http.MethodGet, "/", nil)
+	other.Header.Set("Origin", "https://evil.example")
+	rec = serve(h, other)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Body.String() != "handler" {
+		t.Errorf("disallowed origin got CORS headers or no handler: %v", rec.Header())
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	h := Timeout(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "request timed out" {
+		t.Errorf("status %d, body %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	h := BasicAuth("coffee", StaticCredentials("barista", "secret"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "welcome")
+	}))
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusUnauthorized || !strings.HasPrefix(rec.Header().Get("WWW-Authenticate"), `Basic realm="coffee"`) {
+		t.Errorf("no credentials: status %d, challenge %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+
+	wrong := httptest.NewRequest(http.MethodGet, "/", nil)
+	wrong.SetBasicAuth("barista", "guess")
+	if rec := serve(h, wrong); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password: status %d", rec.Code)
+	}
+
+	right := httptest.NewRequest(http.MethodGet, "/", nil)
+	right.SetBasicAuth("barista", "secret")
+	if rec := serve(h, right); rec.Code != http.StatusOK || rec.Body.String() != "welcome" {
+		t.Errorf("right password: status %d, body %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,279 @@
+package decorator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Prices are kept as an integer number of minor units (cents for USD) plus an ISO 4217 currency code. Adding and
+// multiplying by whole quantities is exact; anything that produces a fraction of a minor unit goes through MulFrac, which
+// rounds with an explicit RoundingMode so the same inputs always print the same total. A result that does not fit in
+// an int64 is never wrapped around: Add and Sub return ErrOverflow, and Neg, Mul and MulFrac, which have no error
+// result, panic with it.
+
+// Currency is an ISO 4217 currency code
+type Currency string
+
+const (
+	USD Currency = "USD"
+	EUR Currency = "EUR"
+	GBP Currency = "GBP"
+	JPY Currency = "JPY"
+)
+
+// minorUnits lists currencies whose minor unit is not 1/100 of the major unit
+var minorUnits = map[Currency]int{
+	JPY:   0,
+	"KRW": 0,
+	"BHD": 3,
+	"KWD": 3,
+	"OMR": 3,
+}
+
+// MinorUnits returns the number of decimal places used by the currency
+func (c Currency) MinorUnits() int {
+	if n, ok := minorUnits[c]; ok {
+		return n
+	}
+	return 2
+}
+
+// RoundingMode controls how MulFrac rounds results that fall between two minor units
+type RoundingMode int
+
+const (
+	// RoundHalfEven rounds to the nearest minor unit and ties to the even one (banker's rounding)
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp rounds to the nearest minor unit and ties away from zero
+	RoundHalfUp
+	// RoundDown truncates towards zero
+	RoundDown
+)
+
+// ErrOverflow is returned or panicked with when a result does not fit in an int64 number of minor units
+var ErrOverflow = errors.New("decorator: money overflow")
+
+// CurrencyMismatchError is returned when two amounts in different currencies are combined
+type CurrencyMismatchError struct {
+	Left, Right Currency
+}
+
+func (e *CurrencyMismatchError) Error() string {
+	return fmt.Sprintf("decorator: currency mismatch: %s and %s", e.Left, e.Right)
+}
+
+// Money is a fixed-point amount of a currency. The zero value is zero with no currency and combines with any currency.
+type Money struct {
+	amount   int64
+	currency Currency
+}
+
+// NewMoney creates an amount from a number of minor units, e.g. NewMoney(650, USD) is 6.50 USD
+func NewMoney(amount int64, currency Currency) Money {
+	return Money{amount: amount, currency: currency}
+}
+
+// ParseMoney parses a decimal amount such as "0.75" or "-3" in the given currency. It rejects more decimal places than
+// the currency has, so no rounding ever happens while parsing.
+func ParseMoney(s string, currency Currency) (Money, error) {
+	digits := currency.MinorUnits()
+	text := strings.TrimSpace(s)
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign = "-"
+	}
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "-"), "+")
+	whole, frac, _ := strings.Cut(text, ".")
+	if whole == "" || len(frac) > digits || strings.ContainsAny(whole+frac, "+-") {
+		return Money{}, fmt.Errorf("decorator: invalid %s amount %q", currency, s)
+	}
+	frac += strings.Repeat("0", digits-len(frac))
+	// parse the sign with the digits so the most negative amount is accepted
+	amount, err := strconv.ParseInt(sign+whole+frac, 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return Money{}, fmt.Errorf("decorator: %s amount %q: %w", currency, s, ErrOverflow)
+	}
+	if err != nil {
+		return Money{}, fmt.Errorf("decorator: invalid %s amount %q: %w", currency, s, err)
+	}
+	return Money{amount: amount, currency: currency}, nil
+}
+
+// Amount returns the amount in minor units
+func (m Money) Amount() int64 {
+	return m.amount
+}
+
+// Currency returns the currency of the amount
+func (m Money) Currency() Currency {
+	return m.currency
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.amount == 0
+}
+
+func (m Money) unify(o Money) (Currency, error) {
+	switch {
+	case m.currency == o.currency:
+		return m.currency, nil
+	case m.currency == "" && m.amount == 0:
+		return o.currency, nil
+	case o.currency == "" && o.amount == 0:
+		return m.currency, nil
+	}
+	return "", &CurrencyMismatchError{Left: m.currency, Right: o.currency}
+}
+
+// Add returns m + o
+func (m Money) Add(o Money) (Money, error) {
+	c, err := m.unify(o)
+	if err != nil {
+		return Money{}, err
+	}
+	if (o.amount > 0 && m.amount > math.MaxInt64-o.amount) || (o.amount < 0 && m.amount < math.MinInt64-o.amount) {
+		return Money{}, fmt.Errorf("%w: %v + %v", ErrOverflow, m, o)
+	}
+	return Money{amount: m.amount + o.amount, currency: c}, nil
+}
+
+// Sub returns m - o
+func (m Money) Sub(o Money) (Money, error) {
+	c, err := m.unify(o)
+	if err != nil {
+		return Money{}, err
+	}
+	if (o.amount < 0 && m.amount > math.MaxInt64+o.amount) || (o.amount > 0 && m.amount < math.MinInt64+o.amount) {
+		return Money{}, fmt.Errorf("%w: %v - %v", ErrOverflow, m, o)
+	}
+	return Money{amount: m.amount - o.amount, currency: c}, nil
+}
+
+// Cmp compares m and o and returns -1, 0 or +1
+func (m Money) Cmp(o Money) (int, error) {
+	if _, err := m.unify(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.amount < o.amount:
+		return -1, nil
+	case m.amount > o.amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Neg returns -m. It panics with ErrOverflow for the most negative amount.
+func (m Money) Neg() Money {
+	if m.amount == math.MinInt64 {
+		panic(fmt.Errorf("%w: -(%v)", ErrOverflow, m))
+	}
+	return Money{amount: -m.amount, currency: m.currency}
+}
+
+// Mul returns m multiplied by a whole quantity. It panics with ErrOverflow if the product does not fit.
+func (m Money) Mul(n int64) Money {
+	p := m.amount * n
+	if m.amount != 0 && (p/m.amount != n || (m.amount == -1 && n == math.MinInt64)) {
+		panic(fmt.Errorf("%w: %v * %d", ErrOverflow, m, n))
+	}
+	return Money{amount: p, currency: m.currency}
+}
+
+// MulFrac returns m * num / den rounded to a whole minor unit with the given mode.
+// For example MulFrac(90, 100, RoundHalfEven) takes 10% off. It panics with ErrOverflow if the result does not fit.
+func (m Money) MulFrac(num, den int64, mode RoundingMode) Money {
+	if den == 0 {
+		panic("decorator: MulFrac with zero denominator")
+	}
+	n := new(big.Int).Mul(big.NewInt(m.amount), big.NewInt(num))
+	d := big.NewInt(den)
+	if d.Sign() < 0 {
+		n.Neg(n)
+		d.Neg(d)
+	}
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	if r.Sign() != 0 && mode != RoundDown {
+		// compare 2|r| with d to find out which side of the halfway point we are on
+		twice := new(big.Int).Abs(r)
+		twice.Lsh(twice, 1)
+		away := false
+		switch twice.Cmp(d) {
+		case 1:
+			away = true
+		case 0:
+			away = mode == RoundHalfUp || q.Bit(0) == 1
+		}
+		if away {
+			q.Add(q, big.NewInt(int64(n.Sign())))
+		}
+	}
+	if !q.IsInt64() {
+		panic(fmt.Errorf("%w: %v * %d / %d", ErrOverflow, m, num, den))
+	}
+	return Money{amount: q.Int64(), currency: m.currency}
+}
+
+// String formats the amount with the currency's decimal places, e.g. "6.50 USD"
+func (m Money) String() string {
+	digits := m.currency.MinorUnits()
+	// the absolute value goes through uint64 because -math.MinInt64 does not fit in an int64
+	amount := uint64(m.amount)
+	sign := ""
+	if m.amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	s := fmt.Sprintf("%0*d", digits+1, amount)
+	if digits > 0 {
+		s = s[:len(s)-digits] + "." + s[len(s)-digits:]
+	}
+	return strings.TrimSpace(sign + s + " " + string(m.currency))
+}
+
+// moneyJSON is the JSON form of Money. Amounts stay in minor units so nothing is lost to floating point.
+type moneyJSON struct {
+	Amount   int64    `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.amount, Currency: m.currency})
+}
+
+func (m *Money) UnmarshalJSON(b []byte) error {
+	var j moneyJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	*m = Money{amount: j.Amount, currency: j.Currency}
+	return nil
+}
+
+// Sum adds all amounts together
+func Sum(amounts ...Money) (Money, error) {
+	var total Money
+	for _, m := range amounts {
+		var err error
+		if total, err = total.Add(m); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// mustAdd is used by decorators, whose Cost method cannot return an error. Mixing currencies inside one drink is a
+// programming error, so it panics with the *CurrencyMismatchError (or ErrOverflow for an absurd total).
+func mustAdd(m, o Money) Money {
+	sum, err := m.Add(o)
+	if err != nil {
+		panic(err)
+	}
+	return sum
+}
@@ -0,0 +1,184 @@
+package decorator
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestMulFracRounding(t *testing.T) {
+	tests := []struct {
+		amount, num, den int64
+		mode             RoundingMode
+		want             int64
+	}{
+		// 2.5, 3.5 and -2.5 sit exactly between two minor units
+		{5, 1, 2, RoundHalfEven, 2},
+		{7, 1, 2, RoundHalfEven, 4},
+		{-5, 1, 2, RoundHalfEven, -2},
+		{5, 1, 2, RoundHalfUp, 3},
+		{7, 1, 2, RoundHalfUp, 4},
+		{-5, 1, 2, RoundHalfUp, -3},
+		{5, 1, 2, RoundDown, 2},
+		{7, 1, 2, RoundDown, 3},
+		{-5, 1, 2, RoundDown, -2},
+		// off the halfway point every mode but RoundDown goes to the nearest unit
+		{10, 2, 3, RoundHalfEven, 7},
+		{10, 2, 3, RoundHalfUp, 7},
+		{10, 2, 3, RoundDown, 6},
+		{-10, 2, 3, RoundHalfEven, -7},
+		{-10, 2, 3, RoundDown, -6},
+		// a negative denominator flips the sign
+		{10, 2, -3, RoundHalfEven, -7},
+		{1000, 90, 100, RoundHalfEven, 900},
+	}
+	for _, tt := range tests {
+		got := NewMoney(tt.amount, USD).MulFrac(tt.num, tt.den, tt.mode)
+		if got != NewMoney(tt.want, USD) {
+			t.Errorf("%d * %d / %d (mode %d) = %v, want %d", tt.amount, tt.num, tt.den, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestParseMoney(t *testing.T) {
+	good := []struct {
+		s        string
+		currency Currency
+		want     int64
+	}{
+		{"0.75", USD, 75},
+		{"6.5", USD, 650},
+		{"-3", EUR, -300},
+		{"+3.00", EUR, 300},
+		{" 12.34 ", USD, 1234},
+		{"600", JPY, 600},
+		{"1.234", "BHD", 1234},
+		{"-92233720368547758.08", USD, math.MinInt64},
+		{"92233720368547758.07", USD, math.MaxInt64},
+	}
+	for _, tt := range good {
+		got, err := ParseMoney(tt.s, tt.currency)
+		if err != nil || got != NewMoney(tt.want, tt.currency) {
+			t.Errorf("ParseMoney(%q, %s) = %v, %v, want %d", tt.s, tt.currency, got, err, tt.want)
+		}
+	}
+
+	bad := []struct {
+		s        string
+		currency Currency
+	}{
+		{"", USD},
+		{".50", USD},
+		{"1.005", USD},
+		{"1.5", JPY},
+		{"--1", USD},
+		{"1-2", USD},
+		{"1.2.3", USD},
+		{"abc", USD},
+		{"1e3", USD},
+	}
+	for _, tt := range bad {
+		if got, err := ParseMoney(tt.s, tt.currency); err == nil {
+			t.Errorf("ParseMoney(%q, %s) = %v, want an error", tt.s, tt.currency, got)
+		}
+	}
+
+	if _, err := ParseMoney("92233720368547758.08", USD); !errors.Is(err, ErrOverflow) {
+		t.Errorf("ParseMoney past the limit: err = %v, want ErrOverflow", err)
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := map[Money]string{
+		{}:                               "0.00",
+		NewMoney(650, USD):               "6.50 USD",
+		NewMoney(-5, EUR):                "-0.05 EUR",
+		NewMoney(600, JPY):               "600 JPY",
+		NewMoney(1234, "BHD"):            "1.234 BHD",
+		NewMoney(math.MinInt64, USD):     "-92233720368547758.08 USD",
+		NewMoney(math.MaxInt64, USD):     "92233720368547758.07 USD",
+		NewMoney(math.MinInt64+1, "KRW"): "-9223372036854775807 KRW",
+	}
+	for m, want := range tests {
+		if got := m.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestMoneyCurrencyMismatch(t *testing.T) {
+	usd, eur := NewMoney(100, USD), NewMoney(100, EUR)
+	var mismatch *CurrencyMismatchError
+	if _, err := usd.Add(eur); !errors.As(err, &mismatch) || mismatch.Left != USD || mismatch.Right != EUR {
+		t.Errorf("Add: err = %v", err)
+	}
+	if _, err := usd.Sub(eur); !errors.As(err, &mismatch) {
+		t.Errorf("Sub: err = %v", err)
+	}
+	if _, err := usd.Cmp(eur); !errors.As(err, &mismatch) {
+		t.Errorf("Cmp: err = %v", err)
+	}
+	if _, err := Sum(usd, usd, eur); !errors.As(err, &mismatch) {
+		t.Errorf("Sum: err = %v", err)
+	}
+
+	// the zero value takes the other side's currency, but a non-zero amount without one does not
+	if got, err := (Money{}).Add(eur); err != nil || got != eur {
+		t.Errorf("zero + EUR = %v, %v", got, err)
+	}
+	if _, err := NewMoney(1, "").Add(eur); !errors.As(err, &mismatch) {
+		t.Errorf("1 without a currency + EUR: err = %v", err)
+	}
+}
+
+func TestMoneyOverflow(t *testing.T) {
+	top, bottom := NewMoney(math.MaxInt64, USD), NewMoney(math.MinInt64, USD)
+	one := NewMoney(1, USD)
+
+	errs := map[string]error{}
+	_, errs["max + 1"] = top.Add(one)
+	_, errs["min + -1"] = bottom.Add(one.Neg())
+	_, errs["min - 1"] = bottom.Sub(one)
+	_, errs["0 - min"] = NewMoney(0, USD).Sub(bottom)
+	_, errs["sum"] = Sum(top, one)
+	for name, err := range errs {
+		if !errors.Is(err, ErrOverflow) {
+			t.Errorf("%s: err = %v, want ErrOverflow", name, err)
+		}
+	}
+	if got, err := bottom.Sub(one.Neg()); err != nil || got.Amount() != math.MinInt64+1 {
+		t.Errorf("min - -1 = %v, %v", got, err)
+	}
+	if got, err := NewMoney(-1, USD).Sub(bottom); err != nil || got != top {
+		t.Errorf("-1 - min = %v, %v", got, err)
+	}
+
+	panics := map[string]func(){
+		"neg":         func() { bottom.Neg() },
+		"mul":         func() { top.Mul(2) },
+		"mul -1":      func() { NewMoney(-1, USD).Mul(math.MinInt64) },
+		"mul min":     func() { bottom.Mul(-1) },
+		"mulfrac":     func() { top.MulFrac(3, 2, RoundHalfEven) },
+		"mustAdd":     func() { mustAdd(top, one) },
+		"half * 2":    func() { NewMoney(math.MaxInt64/2+1, USD).Mul(2) },
+		"mulfrac min": func() { bottom.MulFrac(2, 1, RoundDown) },
+	}
+	for name, f := range panics {
+		func() {
+			defer func() {
+				if err, _ := recover().(error); !errors.Is(err, ErrOverflow) {
+					t.Errorf("%s: recovered %v, want ErrOverflow", name, err)
+				}
+			}()
+			f()
+		}()
+	}
+
+	// products that fit are left alone, including the most negative amount itself
+	if got := NewMoney(math.MinInt64/2, USD).Mul(2); got != bottom {
+		t.Errorf("Mul = %v, want %v", got, bottom)
+	}
+	if got := top.MulFrac(2, 2, RoundHalfEven); got != top {
+		t.Errorf("MulFrac = %v, want %v", got, top)
+	}
+}
@@ -0,0 +1,451 @@
+package decorator
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MilkDecorator and SugarDecorator add a flat amount. The decorators below change the price of everything they wrap
+// instead, so where they sit in the chain matters: a 10% discount around a drink with milk also discounts the milk.
+//
+// To keep that well defined every layer belongs to a Stage, and a chain is evaluated from the inside out in stage order:
+//
+//	StageAdditive    add-ons such as milk, sugar and catalog add-ons (the default for layers that do not say otherwise)
+//	StageMultiplier  size multipliers, which scale the drink including its add-ons
+//	StageDiscount    percentage discounts and happy-hour pricing
+//	StagePromo       promo codes, applied last to the discounted price
+//
+// Rules with Stages set reports chains that break this order, and SortByStage rebuilds a chain into it.
+// Fractions of a minor unit are rounded half to even.
+
+// Stage is the evaluation stage of a layer
+type Stage int
+
+const (
+	StageAdditive Stage = iota
+	StageMultiplier
+	StageDiscount
+	StagePromo
+)
+
+func (s Stage) String() string {
+	switch s {
+	case StageAdditive:
+		return "additive"
+	case StageMultiplier:
+		return "multiplier"
+	case StageDiscount:
+		return "discount"
+	case StagePromo:
+		return "promo"
+	}
+	return fmt.Sprintf("Stage(%d)", int(s))
+}
+
+// Staged is implemented by layers that are not additive
+type Staged interface {
+	Stage() Stage
+}
+
+// StageOf returns the stage of a single layer
+func StageOf(c Coffee) Stage {
+	if s, ok := c.(Staged); ok {
+		return s.Stage()
+	}
+	return StageAdditive
+}
+
+// BasisPoints is a rate in hundredths of a percent
+type BasisPoints int64
+
+// Percent is one percent in basis points, so 15*Percent is 15%
+const Percent BasisPoints = 100
+
+func (b BasisPoints) String() string {
+	sign := ""
+	if b < 0 {
+		sign, b = "-", -b
+	}
+	if b%Percent == 0 {
+		return fmt.Sprintf("%s%d%%", sign, b/Percent)
+	}
+	return fmt.Sprintf("%s%d.%02d%%", sign, b/Percent, b%Percent)
+}
+
+// off returns m reduced by the rate
+func (b BasisPoints) off(m Money) Money {
+	return m.MulFrac(int64(100*Percent-b), int64(100*Percent), RoundHalfEven)
+}
+
+// PercentDiscountDecorator takes a percentage off everything it wraps
+type PercentDiscountDecorator struct {
+	*CoffeeDecorator
+	rate BasisPoints
+}
+
+// NewPercentDiscount wraps a Coffee with a percentage discount
+func NewPercentDiscount(c Coffee, rate BasisPoints) *PercentDiscountDecorator {
+	return &PercentDiscountDecorator{&CoffeeDecorator{c}, rate}
+}
+
+func (d *PercentDiscountDecorator) Cost() Money {
+	return d.rate.off(d.CoffeeDecorator.Cost())
+}
+
+func (d *PercentDiscountDecorator) Description() string {
+	return d.CoffeeDecorator.Description() + ", " + d.rate.String() + " off"
+}
+
+// Name returns "discount"
+func (d *PercentDiscountDecorator) Name() string {
+	return "discount"
+}
+
+// Stage returns StageDiscount
+func (d *PercentDiscountDecorator) Stage() Stage {
+	return StageDiscount
+}
+
+// Rewrap returns the same discount around the given Coffee
+func (d *PercentDiscountDecorator) Rewrap(inner Coffee) Coffee {
+	return NewPercentDiscount(inner, d.rate)
+}
+
+// TimeWindow is a daily time range, such as 15:00 to 17:00 on weekdays. End is exclusive.
+type TimeWindow struct {
+	Start, End time.Duration // offset from midnight
+	// Days limits the window to some days of the week. Empty means every day.
+	Days []time.Weekday
+}
+
+// Contains reports whether the wall clock time of t, in t's location, falls inside the window. On days with a daylight
+// saving change the window still follows the clock on the wall rather than the time elapsed since midnight.
+func (w TimeWindow) Contains(t time.Time) bool {
+	if len(w.Days) > 0 && !slices.Contains(w.Days, t.Weekday()) {
+		return false
+	}
+	h, m, sec := t.Clock()
+	offset := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second +
+		time.Duration(t.Nanosecond())
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	// the window wraps past midnight
+	return offset >= w.Start || offset < w.End
+}
+
+// HappyHourDecorator takes a percentage off while its clock is inside the window
+type HappyHourDecorator struct {
+	*CoffeeDecorator
+	window TimeWindow
+	rate   BasisPoints
+	clock  Clock
+}
+
+// NewHappyHourDecorator wraps a Coffee with time-window pricing read from the clock
+func NewHappyHourDecorator(c Coffee, window TimeWindow, rate BasisPoints, clock Clock) *HappyHourDecorator {
+	return &HappyHourDecorator{&CoffeeDecorator{c}, window, rate, clock}
+}
+
+// Active reports whether the happy hour applies right now
+func (d *HappyHourDecorator) Active() bool {
+	return d.window.Contains(d.clock.Now())
+}
+
+func (d *HappyHourDecorator) Cost() Money {
+	if !d.Active() {
+		return d.CoffeeDecorator.Cost()
+	}
+	return d.rate.off(d.CoffeeDecorator.Cost())
+}
+
+func (d *HappyHourDecorator) Description() string {
+	if !d.Active() {
+		return d.CoffeeDecorator.Description()
+	}
+	return d.CoffeeDecorator.Description() + ", happy hour " + d.rate.String() + " off"
+}
+
+// Name returns "happy-hour"
+func (d *HappyHourDecorator) Name() string {
+	return "happy-hour"
+}
+
+// Stage returns StageDiscount
+func (d *HappyHourDecorator) Stage() Stage {
+	return StageDiscount
+}
+
+// Rewrap returns the same happy hour around the given Coffee
+func (d *HappyHourDecorator) Rewrap(inner Coffee) Coffee {
+	return NewHappyHourDecorator(inner, d.window, d.rate, d.clock)
+}
+
+// Size is the size of a drink
+type Size int
+
+const (
+	Small Size = iota
+	Medium
+	Large
+)
+
+// sizeFactors are the price multipliers of each size as fractions
+var sizeFactors = map[Size][2]int64{
+	Small:  {1, 1},
+	Medium: {5, 4},
+	Large:  {3, 2},
+}
+
+func (s Size) String() string {
+	switch s {
+	case Small:
+		return "small"
+	case Medium:
+		return "medium"
+	case Large:
+		return "large"
+	}
+	return fmt.Sprintf("Size(%d)", int(s))
+}
+
+// ParseSize parses "small", "medium" or "large"
+func ParseSize(s string) (Size, error) {
+	for size := range sizeFactors {
+		if strings.EqualFold(s, size.String()) {
+			return size, nil
+		}
+	}
+	return 0, fmt.Errorf("decorator: unknown size %q", s)
+}
+
+// SizeDecorator multiplies the price of everything it wraps: small is 1x, medium 1.25x and large 1.5x
+type SizeDecorator struct {
+	*CoffeeDecorator
+	size Size
+}
+
+// NewSizeDecorator wraps a Coffee with a size
+func NewSizeDecorator(c Coffee, size Size) *SizeDecorator {
+	return &SizeDecorator{&CoffeeDecorator{c}, size}
+}
+
+func (d *SizeDecorator) Cost() Money {
+	f, ok := sizeFactors[d.size]
+	if !ok {
+		panic(fmt.Sprintf("decorator: unknown size %d", d.size))
+	}
+	return d.CoffeeDecorator.Cost().MulFrac(f[0], f[1], RoundHalfEven)
+}
+
+func (d *SizeDecorator) Description() string {
+	return d.CoffeeDecorator.Description() + ", " + d.size.String()
+}
+
+// Size returns the size of the drink
+func (d *SizeDecorator) Size() Size {
+	return d.size
+}
+
+// Name returns "size"
+func (d *SizeDecorator) Name() string {
+	return "size"
+}
+
+// Stage returns StageMultiplier
+func (d *SizeDecorator) Stage() Stage {
+	return StageMultiplier
+}
+
+// Rewrap returns the same size around the given Coffee
+func (d *SizeDecorator) Rewrap(inner Coffee) Coffee {
+	return NewSizeDecorator(inner, d.size)
+}
+
+// Promo is a promo code worth a percentage or a flat amount off. When both are set the percentage is applied first.
+// A promo never takes the price below zero.
+type Promo struct {
+	Code   string
+	Rate   BasisPoints
+	Amount Money
+}
+
+// UnknownPromoError is returned for codes that are not in the PromoBook
+type UnknownPromoError struct {
+	Code string
+}
+
+func (e *UnknownPromoError) Error() string {
+	return fmt.Sprintf("decorator: unknown promo code %q", e.Code)
+}
+
+// PromoBook looks promo codes up case-insensitively
+type PromoBook map[string]Promo
+
+// NewPromoBook creates a book from the given promos
+func NewPromoBook(promos ...Promo) PromoBook {
+	book := make(PromoBook, len(promos))
+	for _, p := range promos {
+		book[strings.ToUpper(p.Code)] = p
+	}
+	return book
+}
+
+// Lookup returns the promo for a code
+func (b PromoBook) Lookup(code string) (Promo, error) {
+	p, ok := b[strings.ToUpper(strings.TrimSpace(code))]
+	if !ok {
+		return Promo{}, &UnknownPromoError{Code: code}
+	}
+	return p, nil
+}
+
+// Decorator returns a Decorator[Coffee] that applies the promo code
+func (b PromoBook) Decorator(code string) (Decorator[Coffee], error) {
+	p, err := b.Lookup(code)
+	if err != nil {
+		return nil, err
+	}
+	return func(c Coffee) Coffee { return NewPromoDecorator(c, p) }, nil
+}
+
+// PromoDecorator applies a promo code to everything it wraps
+type PromoDecorator struct {
+	*CoffeeDecorator
+	promo Promo
+}
+
+// NewPromoDecorator wraps a Coffee with a promo
+func NewPromoDecorator(c Coffee, p Promo) *PromoDecorator {
+	return &PromoDecorator{&CoffeeDecorator{c}, p}
+}
+
+func (d *PromoDecorator) Cost() Money {
+	cost := d.CoffeeDecorator.Cost()
+	if d.promo.Rate != 0 {
+		cost = d.promo.Rate.off(cost)
+	}
+	if !d.promo.Amount.IsZero() {
+		cost = mustAdd(cost, d.promo.Amount.Neg())
+	}
+	if cost.Amount() < 0 {
+		cost = NewMoney(0, cost.Currency())
+	}
+	return cost
+}
+
+func (d *PromoDecorator) Description() string {
+	return d.CoffeeDecorator.Description() + ", promo " + d.promo.Code
+}
+
+// Promo returns the applied promo
+func (d *PromoDecorator) Promo() Promo {
+	return d.promo
+}
+
+// Name returns "promo"
+func (d *PromoDecorator) Name() string {
+	return "promo"
+}
+
+// Stage returns StagePromo
+func (d *PromoDecorator) Stage() Stage {
+	return StagePromo
+}
+
+// Rewrap returns the same promo around the given Coffee
+func (d *PromoDecorator) Rewrap(inner Coffee) Coffee {
+	return NewPromoDecorator(inner, d.promo)
+}
+
+// PricingRules allows one size, one happy hour and one promo per drink, and enforces stage order
+var PricingRules = &Rules{
+	MaxCount: map[string]int{"size": 1, "happy-hour": 1, "promo": 1},
+	Stages:   true,
+}
+
+// SortByStage rebuilds the chain so that its layers are in stage order. Layers within a stage keep their relative
+// order. Every layer above the base must implement Rewrapper.
+func SortByStage(c Coffee) (Coffee, error) {
+	layers := Layers(c)
+	if len(layers) == 0 {
+		return c, nil
+	}
+	slices.Reverse(layers)
+	base, decorators := layers[0], layers[1:]
+	slices.SortStableFunc(decorators, func(a, b Coffee) int {
+		return int(StageOf(a)) - int(StageOf(b))
+	})
+	result := base
+	for _, layer := range decorators {
+		rw, ok := layer.(Rewrapper)
+		if !ok {
+			return nil, &NotRewrappableError{Layer: layer}
+		}
+		result = rw.Rewrap(result)
+	}
+	return result, nil
+}
+
+// registerPricing adds codecs for the pricing decorators. Happy hours are decoded with the registry's clock.
+func registerPricing(r *Registry) {
+	RegisterLayer(r, "discount",
+		func(d *PercentDiscountDecorator) ([]byte, error) {
+			return json.Marshal(struct {
+				Rate BasisPoints `json:"rate"`
+			}{d.rate})
+		},
+		func(data []byte, inner Coffee) (*PercentDiscountDecorator, error) {
+			var v struct {
+				Rate BasisPoints `json:"rate"`
+			}
+			err := json.Unmarshal(data, &v)
+			return NewPercentDiscount(inner, v.Rate), err
+		})
+	RegisterLayer(r, "happy-hour",
+		func(d *HappyHourDecorator) ([]byte, error) {
+			return json.Marshal(happyHourJSON{d.window.Start, d.window.End, d.window.Days, d.rate})
+		},
+		func(data []byte, inner Coffee) (*HappyHourDecorator, error) {
+			var v happyHourJSON
+			err := json.Unmarshal(data, &v)
+			return NewHappyHourDecorator(inner, TimeWindow{v.Start, v.End, v.Days}, v.Rate, r.clock), err
+		})
+	RegisterLayer(r, "size",
+		func(d *SizeDecorator) ([]byte, error) {
+			return json.Marshal(d.size.String())
+		},
+		func(data []byte, inner Coffee) (*SizeDecorator, error) {
+			var name string
+			if err := json.Unmarshal(data, &name); err != nil {
+				return nil, err
+			}
+			size, err := ParseSize(name)
+			return NewSizeDecorator(inner, size), err
+		})
+	RegisterLayer(r, "promo",
+		func(d *PromoDecorator) ([]byte, error) {
+			return json.Marshal(promoJSON(d.promo))
+		},
+		func(data []byte, inner Coffee) (*PromoDecorator, error) {
+			var v promoJSON
+			err := json.Unmarshal(data, &v)
+			return NewPromoDecorator(inner, Promo(v)), err
+		})
+}
+
+type happyHourJSON struct {
+	Start time.Duration  `json:"start"`
+	End   time.Duration  `json:"end"`
+	Days  []time.Weekday `json:"days,omitempty"`
+	Rate  BasisPoints    `json:"rate"`
+}
+
+type promoJSON struct {
+	Code   string      `json:"code"`
+	Rate   BasisPoints `json:"rate,omitempty"`
+	Amount Money       `json:"amount"`
+}
@@ -0,0 +1,108 @@
+package decorator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBasisPointsString(t *testing.T) {
+	tests := map[BasisPoints]string{
+		0:    "0%",
+		1500: "15%",
+		1250: "12.50%",
+		5:    "0.05%",
+		-150: "-1.50%",
+		-200: "-2%",
+		-5:   "-0.05%",
+	}
+	for b, want := range tests {
+		if got := b.String(); got != want {
+			t.Errorf("BasisPoints(%d).String() = %q, want %q", int64(b), got, want)
+		}
+	}
+}
+
+func TestTimeWindowContains(t *testing.T) {
+	afternoon := TimeWindow{Start: 15 * time.Hour, End: 17 * time.Hour, Days: []time.Weekday{time.Sunday}}
+	night := TimeWindow{Start: 22 * time.Hour, End: 2 * time.Hour}
+	at := func(day, hour, min int) time.Time { return time.Date(2024, 3, day, hour, min, 0, 0, time.UTC) }
+	tests := []struct {
+		window TimeWindow
+		t      time.Time
+		want   bool
+	}{
+		{afternoon, at(10, 15, 0), true},
+		{afternoon, at(10, 16, 59), true},
+		{afternoon, at(10, 17, 0), false},
+		{afternoon, at(11, 15, 30), false}, // Monday
+		{night, at(11, 23, 0), true},
+		{night, at(11, 1, 0), true},
+		{night, at(11, 12, 0), false},
+	}
+	for _, tt := range tests {
+		if got := tt.window.Contains(tt.t); got != tt.want {
+			t.Errorf("%v.Contains(%v) = %v, want %v", tt.window, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestTimeWindowDaylightSaving(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	window := TimeWindow{Start: 15 * time.Hour, End: 17 * time.Hour}
+	// clocks go forward on 2024-03-10 and back on 2024-11-03, so these days are 23 and 25 hours long
+	for _, day := range []time.Time{time.Date(2024, 3, 10, 0, 0, 0, 0, loc), time.Date(2024, 11, 3, 0, 0, 0, 0, loc)} {
+		y, m, d := day.Date()
+		if !window.Contains(time.Date(y, m, d, 15, 30, 0, 0, loc)) {
+			t.Errorf("15:30 on %s is outside the window", day.Format(time.DateOnly))
+		}
+		if window.Contains(time.Date(y, m, d, 14, 30, 0, 0, loc)) || window.Contains(time.Date(y, m, d, 17, 0, 0, 0, loc)) {
+			t.Errorf("the window on %s is shifted by an hour", day.Format(time.DateOnly))
+		}
+	}
+}
+
+func TestHappyHourFollowsClock(t *testing.T) {
+	clock := newFakeClock() // 12:00
+	c := NewHappyHourDecorator(&SimpleCoffee{}, TimeWindow{Start: 15 * time.Hour, End: 17 * time.Hour}, 50*Percent, clock)
+	full := (&SimpleCoffee{}).Cost()
+	if c.Active() || c.Cost() != full {
+		t.Errorf("before the window: active %v, cost %v", c.Active(), c.Cost())
+	}
+	clock.Advance(3 * time.Hour)
+	if !c.Active() || c.Cost().Amount() != full.Amount()/2 {
+		t.Errorf("inside the window: active %v, cost %v", c.Active(), c.Cost())
+	}
+}
+
+func TestPricingRulesEmptyChain(t *testing.T) {
+	if err := PricingRules.Validate(nil); err != nil {
+		t.Errorf("Validate(nil) = %v", err)
+	}
+	if err := PricingRules.Validate(&SimpleCoffee{}); err != nil {
+		t.Errorf("Validate(base) = %v", err)
+	}
+	if c, err := SortByStage(nil); c != nil || err != nil {
+		t.Errorf("SortByStage(nil) = %v, %v", c, err)
+	}
+}
+
+func TestSortByStage(t *testing.T) {
+	c := NewPromoDecorator(NewSizeDecorator(NewPercentDiscount(NewMilkDecorator(&SimpleCoffee{}), 10*Percent), Large),
+		Promo{Code: "X", Rate: 5 * Percent})
+	if PricingRules.Validate(c) == nil {
+		t.Fatal("a discount inside a size passed validation")
+	}
+	sorted, err := SortByStage(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := PricingRules.Validate(sorted); err != nil {
+		t.Errorf("sorted chain: %v", err)
+	}
+	if got := sorted.Description(); got != "Simple coffee, milk, large, 10% off, promo X" {
+		t.Errorf("Description() = %q", got)
+	}
+}
@@ -0,0 +1,95 @@
+package decorator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// Description() and Cost() flatten a chain into one string and one amount. Breakdown walks the layers instead and turns
+// each one into a LineItem: the base coffee first, then every add-on with the price it added. The price of a layer is
+// the difference between its cost and the cost of the Coffee it wraps, so any decorator can be itemized without
+// knowing anything about it, which is why Breakdown is a function rather than a method every decorator would have to
+// implement. Identical consecutive layers are merged into one line with a quantity.
+
+// LineItem is one line of a receipt
+type LineItem struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	UnitPrice   Money  `json:"unit_price"`
+	Quantity    int    `json:"quantity"`
+	Amount      Money  `json:"amount"`
+}
+
+// Receipt is an itemized Coffee
+type Receipt struct {
+	Items []LineItem `json:"items"`
+	Total Money      `json:"total"`
+}
+
+// Breakdown itemizes any Coffee chain, base item first
+func Breakdown(c Coffee) Receipt {
+	layers := Layers(c)
+	receipt := Receipt{Total: c.Cost()}
+	for i := len(layers) - 1; i >= 0; i-- {
+		item := lineItem(layers[i])
+		if n := len(receipt.Items); n > 0 {
+			last := &receipt.Items[n-1]
+			if last.Name == item.Name && last.UnitPrice == item.UnitPrice {
+				last.Quantity++
+				last.Amount = last.UnitPrice.Mul(int64(last.Quantity))
+				continue
+			}
+		}
+		receipt.Items = append(receipt.Items, item)
+	}
+	return receipt
+}
+
+// lineItem describes what a single layer adds on top of the Coffee it wraps
+func lineItem(layer Coffee) LineItem {
+	item := LineItem{Name: LayerName(layer), Description: layer.Description(), UnitPrice: layer.Cost(), Quantity: 1}
+	if inner := Unwrap(layer); inner != nil {
+		item.UnitPrice = mustAdd(item.UnitPrice, inner.Cost().Neg())
+		item.Description = item.Name
+		if rest, ok := strings.CutPrefix(layer.Description(), inner.Description()); ok && rest != "" {
+			item.Description = strings.TrimLeft(rest, ", ")
+		}
+	}
+	item.Amount = item.UnitPrice
+	return item
+}
+
+// Text renders the receipt as aligned plain text
+func (r Receipt) Text() string {
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	for _, item := range r.Items {
+		fmt.Fprintf(tw, "%s\tx%d\t%s\n", item.Description, item.Quantity, item.Amount)
+	}
+	fmt.Fprintf(tw, "Total\t\t%s\n", r.Total)
+	tw.Flush()
+	return sb.String()
+}
+
+// Markdown renders the receipt as a Markdown table
+func (r Receipt) Markdown() string {
+	var sb strings.Builder
+	sb.WriteString("| Item | Qty | Unit price | Amount |\n")
+	sb.WriteString("| --- | ---: | ---: | ---: |\n")
+	for _, item := range r.Items {
+		fmt.Fprintf(&sb, "| %s | %d | %s | %s |\n", markdownEscape(item.Description), item.Quantity, item.UnitPrice, item.Amount)
+	}
+	fmt.Fprintf(&sb, "| **Total** | | | **%s** |\n", r.Total)
+	return sb.String()
+}
+
+// JSON renders the receipt as indented JSON
+func (r Receipt) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,70 @@
+package decorator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakdown(t *testing.T) {
+	clock := newFakeClock() // 12:00, inside the window
+	lunch := TimeWindow{Start: 11 * time.Hour, End: 14 * time.Hour}
+	oat := &AddOn{Name: "oat-milk", Description: "oat milk", Price: NewMoney(75, USD)}
+	c := NewPromoDecorator(
+		NewHappyHourDecorator(
+			NewPercentDiscount(
+				NewSizeDecorator(NewAddOnDecorator(NewSugarDecorator(NewSugarDecorator(NewMilkDecorator(&SimpleCoffee{}))), oat), Large),
+				10*Percent),
+			lunch, 50*Percent, clock),
+		Promo{Code: "TEN", Amount: NewMoney(10, USD)})
+
+	// 5.00 + 1.00 + 2 * 0.50 + 0.75 = 7.75, large 11.625 rounded half to even 11.62, 10% off 10.46, happy hour 5.23,
+	// promo 5.13
+	want := []LineItem{
+		{Name: "simple", Description: "Simple coffee", UnitPrice: NewMoney(500, USD), Quantity: 1, Amount: NewMoney(500, USD)},
+		{Name: "milk", Description: "milk", UnitPrice: NewMoney(100, USD), Quantity: 1, Amount: NewMoney(100, USD)},
+		{Name: "sugar", Description: "sugar", UnitPrice: NewMoney(50, USD), Quantity: 2, Amount: NewMoney(100, USD)},
+		{Name: "oat-milk", Description: "oat milk", UnitPrice: NewMoney(75, USD), Quantity: 1, Amount: NewMoney(75, USD)},
+		{Name: "size", Description: "large", UnitPrice: NewMoney(387, USD), Quantity: 1, Amount: NewMoney(387, USD)},
+		{Name: "discount", Description: "10% off", UnitPrice: NewMoney(-116, USD), Quantity: 1, Amount: NewMoney(-116, USD)},
+		{Name: "happy-hour", Description: "happy hour 50% off", UnitPrice: NewMoney(-523, USD), Quantity: 1, Amount: NewMoney(-523, USD)},
+		{Name: "promo", Description: "promo TEN", UnitPrice: NewMoney(-10, USD), Quantity: 1, Amount: NewMoney(-10, USD)},
+	}
+	checkReceipt(t, Breakdown(c), want, c.Cost())
+
+	// outside the window the happy hour adds nothing
+	clock.Advance(4 * time.Hour)
+	items := Breakdown(c).Items
+	if hh := items[6]; hh.Name != "happy-hour" || !hh.Amount.IsZero() {
+		t.Errorf("happy hour outside the window: %+v", hh)
+	}
+}
+
+func TestBreakdownDrink(t *testing.T) {
+	d := NewDrink(&SimpleCoffee{}).With(MilkLayer).With(SugarLayer)
+	want := []LineItem{
+		{Name: "simple", Description: "Simple coffee", UnitPrice: NewMoney(500, USD), Quantity: 1, Amount: NewMoney(500, USD)},
+		{Name: "milk", Description: "milk", UnitPrice: NewMoney(100, USD), Quantity: 1, Amount: NewMoney(100, USD)},
+		{Name: "sugar", Description: "sugar", UnitPrice: NewMoney(50, USD), Quantity: 1, Amount: NewMoney(50, USD)},
+	}
+	checkReceipt(t, Breakdown(d), want, d.Cost())
+}
+
+func checkReceipt(t *testing.T, got Receipt, want []LineItem, total Money) {
+	t.Helper()
+	if got.Total != total {
+		t.Errorf("Total = %v, want %v", got.Total, total)
+	}
+	if len(got.Items) != len(want) {
+		t.Fatalf("got %d items, want %d: %+v", len(got.Items), len(want), got.Items)
+	}
+	sum := Money{}
+	for i, item := range got.Items {
+		if item != want[i] {
+			t.Errorf("item %d = %+v, want %+v", i, item, want[i])
+		}
+		sum = mustAdd(sum, item.Amount)
+	}
+	if sum != total {
+		t.Errorf("items add up to %v, want %v", sum, total)
+	}
+}
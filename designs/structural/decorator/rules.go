@@ -0,0 +1,230 @@
+package decorator
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Decorators can be stacked in any order and any number of times. Rules is a validation layer on top of that: it looks
+// at the names of the layers of a finished chain (see LayerName) and reports every rule the chain breaks, rather than
+// stopping at the first one.
+//
+// Positions count from the inside out: the base Coffee is position 0, the first decorator applied is position 1, and so on.
+
+// RuleKind identifies the kind of rule a Violation broke
+type RuleKind string
+
+const (
+	RuleMaxCount  RuleKind = "max-count"
+	RuleExclusive RuleKind = "exclusive"
+	RuleRequires  RuleKind = "requires"
+	RuleOrder     RuleKind = "order"
+	RuleStage     RuleKind = "stage"
+)
+
+// Rules constrains which layers a Coffee chain may contain and in which order
+type Rules struct {
+	// MaxCount limits how many times a layer may appear
+	MaxCount map[string]int
+	// Exclusive lists groups of layers of which at most one may appear
+	Exclusive [][]string
+	// Requires lists, for a layer, the layers that must appear inside it
+	Requires map[string][]string
+	// Order is the canonical order of layers, innermost first. Listed layers must keep this relative order, layers
+	// that are not listed may appear anywhere.
+	Order []string
+	// Stages requires layers to be in stage order, see Stage
+	Stages bool
+}
+
+// Violation describes one broken rule
+type Violation struct {
+	Rule     RuleKind
+	Layer    string
+	Position int
+	Detail   string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s at position %d: %s", v.Rule, v.Layer, v.Position, v.Detail)
+}
+
+// ValidationError lists every rule broken by a chain
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	return fmt.Sprintf("decorator: %d rule violation(s): %s", len(e.Violations), strings.Join(msgs, "; "))
+}
+
+// Has reports whether any violation is of the given kind
+func (e *ValidationError) Has(kind RuleKind) bool {
+	return slices.ContainsFunc(e.Violations, func(v Violation) bool { return v.Rule == kind })
+}
+
+// Validate checks the chain against the rules and returns a *ValidationError listing every violation, or nil
+func (r *Rules) Validate(c Coffee) error {
+	// reverse so that layers[0] is the base coffee
+	layers := Layers(c)
+	slices.Reverse(layers)
+	names := make([]string, len(layers))
+	for i, layer := range layers {
+		names[i] = LayerName(layer)
+	}
+
+	var violations []Violation
+	violations = append(violations, r.checkMaxCount(names)...)
+	violations = append(violations, r.checkExclusive(names)...)
+	violations = append(violations, r.checkRequires(names)...)
+	violations = append(violations, r.checkOrder(names)...)
+	if r.Stages {
+		violations = append(violations, checkStages(layers)...)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return &ValidationError{Violations: violations}
+}
+
+// Apply wraps the Coffee with the decorators and validates the result
+func (r *Rules) Apply(c Coffee, decorators ...Decorator[Coffee]) (Coffee, error) {
+	c = Apply(c, decorators...)
+	if err := r.Validate(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Merge returns rules that enforce both r and o. The lower of two max counts wins, and o's Order replaces r's when set.
+func (r *Rules) Merge(o *Rules) *Rules {
+	merged := &Rules{
+		MaxCount:  make(map[string]int),
+		Exclusive: slices.Concat(r.Exclusive, o.Exclusive),
+		Requires:  make(map[string][]string),
+		Order:     r.Order,
+		Stages:    r.Stages || o.Stages,
+	}
+	if len(o.Order) > 0 {
+		merged.Order = o.Order
+	}
+	for _, rules := range []*Rules{r, o} {
+		for name, limit := range rules.MaxCount {
+			if cur, ok := merged.MaxCount[name]; !ok || limit < cur {
+				merged.MaxCount[name] = limit
+			}
+		}
+		for name, reqs := range rules.Requires {
+			merged.Requires[name] = append(merged.Requires[name], reqs...)
+		}
+	}
+	return merged
+}
+
+func (r *Rules) checkMaxCount(names []string) []Violation {
+	var violations []Violation
+	counts := make(map[string]int)
+	for pos, name := range names {
+		counts[name]++
+		if limit, ok := r.MaxCount[name]; ok && counts[name] == limit+1 {
+			violations = append(violations, Violation{
+				Rule:     RuleMaxCount,
+				Layer:    name,
+				Position: pos,
+				Detail:   fmt.Sprintf("may appear at most %d time(s)", limit),
+			})
+		}
+	}
+	return violations
+}
+
+func (r *Rules) checkExclusive(names []string) []Violation {
+	var violations []Violation
+	for _, group := range r.Exclusive {
+		var found []string
+		pos := -1
+		for i, name := range names {
+			if slices.Contains(group, name) && !slices.Contains(found, name) {
+				found = append(found, name)
+				if len(found) == 2 {
+					pos = i
+				}
+			}
+		}
+		if len(found) > 1 {
+			violations = append(violations, Violation{
+				Rule:     RuleExclusive,
+				Layer:    found[1],
+				Position: pos,
+				Detail:   fmt.Sprintf("cannot be combined: %s", strings.Join(found, ", ")),
+			})
+		}
+	}
+	return violations
+}
+
+func (r *Rules) checkRequires(names []string) []Violation {
+	var violations []Violation
+	for pos, name := range names {
+		for _, req := range r.Requires[name] {
+			if !slices.Contains(names[:pos], req) {
+				violations = append(violations, Violation{
+					Rule:     RuleRequires,
+					Layer:    name,
+					Position: pos,
+					Detail:   fmt.Sprintf("requires %s to be applied first", req),
+				})
+			}
+		}
+	}
+	return violations
+}
+
+func (r *Rules) checkOrder(names []string) []Violation {
+	var violations []Violation
+	highest, highestName := -1, ""
+	for pos, name := range names {
+		rank := slices.Index(r.Order, name)
+		if rank < 0 {
+			continue
+		}
+		if rank < highest {
+			violations = append(violations, Violation{
+				Rule:     RuleOrder,
+				Layer:    name,
+				Position: pos,
+				Detail:   fmt.Sprintf("must be applied before %s", highestName),
+			})
+			continue
+		}
+		highest, highestName = rank, name
+	}
+	return violations
+}
+
+func checkStages(layers []Coffee) []Violation {
+	if len(layers) < 2 {
+		return nil
+	}
+	var violations []Violation
+	highest, highestName := StageAdditive, ""
+	for pos, layer := range layers[1:] {
+		stage := StageOf(layer)
+		if stage < highest {
+			violations = append(violations, Violation{
+				Rule:     RuleStage,
+				Layer:    LayerName(layer),
+				Position: pos + 1,
+				Detail:   fmt.Sprintf("%s layer must be applied before %s (%s)", stage, highestName, highest),
+			})
+			continue
+		}
+		highest, highestName = stage, LayerName(layer)
+	}
+	return violations
+}
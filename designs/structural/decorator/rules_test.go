@@ -0,0 +1,148 @@
+package decorator
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// buildChain wraps a SimpleCoffee in milk, sugar and oat-milk layers, innermost first
+func buildChain(names ...string) Coffee {
+	oat := &AddOn{Name: "oat-milk", Description: "oat milk", Price: NewMoney(70, USD)}
+	var c Coffee = &SimpleCoffee{}
+	for _, name := range names {
+		switch name {
+		case "milk":
+			c = NewMilkDecorator(c)
+		case "sugar":
+			c = NewSugarDecorator(c)
+		case "oat-milk":
+			c = NewAddOnDecorator(c, oat)
+		default:
+			panic("buildChain: unknown layer " + name)
+		}
+	}
+	return c
+}
+
+func TestRulesValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+		chain []string
+		want  []Violation
+	}{
+		{"max count within the limit", Rules{MaxCount: map[string]int{"sugar": 2}}, []string{"sugar", "sugar"}, nil},
+		{"max count exceeded once per layer", Rules{MaxCount: map[string]int{"sugar": 2}}, []string{"sugar", "milk", "sugar", "sugar", "sugar"},
+			[]Violation{{RuleMaxCount, "sugar", 4, "may appear at most 2 time(s)"}}},
+		{"max count of zero", Rules{MaxCount: map[string]int{"milk": 0}}, []string{"milk"},
+			[]Violation{{RuleMaxCount, "milk", 1, "may appear at most 0 time(s)"}}},
+		{"exclusive alone", Rules{Exclusive: [][]string{{"milk", "oat-milk"}}}, []string{"milk", "milk", "sugar"}, nil},
+		{"exclusive combined", Rules{Exclusive: [][]string{{"milk", "oat-milk"}}}, []string{"milk", "sugar", "oat-milk", "milk"},
+			[]Violation{{RuleExclusive, "oat-milk", 3, "cannot be combined: milk, oat-milk"}}},
+		{"requires met", Rules{Requires: map[string][]string{"sugar": {"milk"}}}, []string{"milk", "sugar"}, nil},
+		{"requires applied later", Rules{Requires: map[string][]string{"sugar": {"milk"}}}, []string{"sugar", "milk"},
+			[]Violation{{RuleRequires, "sugar", 1, "requires milk to be applied first"}}},
+		{"requires every listed layer", Rules{Requires: map[string][]string{"sugar": {"milk", "oat-milk"}}}, []string{"sugar"},
+			[]Violation{
+				{RuleRequires, "sugar", 1, "requires milk to be applied first"},
+				{RuleRequires, "sugar", 1, "requires oat-milk to be applied first"},
+			}},
+		{"order kept", Rules{Order: []string{"milk", "sugar"}}, []string{"milk", "oat-milk", "sugar"}, nil},
+		{"order broken", Rules{Order: []string{"milk", "sugar"}}, []string{"sugar", "oat-milk", "milk"},
+			[]Violation{{RuleOrder, "milk", 3, "must be applied before sugar"}}},
+		{"no rules", Rules{}, []string{"sugar", "sugar", "milk"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rules.Validate(buildChain(tt.chain...))
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Validate = %v", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("err = %v, want a *ValidationError", err)
+			}
+			if !slices.Equal(verr.Violations, tt.want) {
+				t.Errorf("violations = %v, want %v", verr.Violations, tt.want)
+			}
+		})
+	}
+}
+
+func TestRulesValidateReportsEveryViolation(t *testing.T) {
+	rules := &Rules{
+		MaxCount:  map[string]int{"sugar": 1},
+		Exclusive: [][]string{{"milk", "oat-milk"}},
+		Requires:  map[string][]string{"oat-milk": {"sugar"}},
+		Order:     []string{"milk", "sugar"},
+	}
+	err := rules.Validate(buildChain("oat-milk", "sugar", "sugar", "milk"))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err = %v, want a *ValidationError", err)
+	}
+	want := []Violation{
+		{RuleMaxCount, "sugar", 3, "may appear at most 1 time(s)"},
+		{RuleExclusive, "milk", 4, "cannot be combined: oat-milk, milk"},
+		{RuleRequires, "oat-milk", 1, "requires sugar to be applied first"},
+		{RuleOrder, "milk", 4, "must be applied before sugar"},
+	}
+	if !slices.Equal(verr.Violations, want) {
+		t.Errorf("violations = %v, want %v", verr.Violations, want)
+	}
+	for _, kind := range []RuleKind{RuleMaxCount, RuleExclusive, RuleRequires, RuleOrder} {
+		if !verr.Has(kind) {
+			t.Errorf("Has(%s) = false", kind)
+		}
+	}
+	if verr.Has(RuleStage) {
+		t.Error("Has(stage) = true")
+	}
+	if got := verr.Error(); !strings.HasPrefix(got, "decorator: 4 rule violation(s): max-count: sugar at position 3") {
+		t.Errorf("Error() = %q", got)
+	}
+
+	if _, err := rules.Apply(&SimpleCoffee{}, WithMilk, WithSugar, WithSugar); !errors.As(err, &verr) || len(verr.Violations) != 1 {
+		t.Errorf("Apply: err = %v, want one violation", err)
+	}
+}
+
+func TestRulesMerge(t *testing.T) {
+	a := &Rules{
+		MaxCount:  map[string]int{"sugar": 3, "milk": 1},
+		Exclusive: [][]string{{"milk", "oat-milk"}},
+		Requires:  map[string][]string{"sugar": {"milk"}},
+		Order:     []string{"milk", "sugar"},
+	}
+	b := &Rules{
+		MaxCount:  map[string]int{"sugar": 1, "oat-milk": 2},
+		Exclusive: [][]string{{"sugar", "oat-milk"}},
+		Requires:  map[string][]string{"sugar": {"oat-milk"}, "milk": {"sugar"}},
+		Stages:    true,
+	}
+	want := &Rules{
+		MaxCount:  map[string]int{"sugar": 1, "milk": 1, "oat-milk": 2},
+		Exclusive: [][]string{{"milk", "oat-milk"}, {"sugar", "oat-milk"}},
+		Requires:  map[string][]string{"sugar": {"milk", "oat-milk"}, "milk": {"sugar"}},
+		Order:     []string{"milk", "sugar"},
+		Stages:    true,
+	}
+	if got := a.Merge(b); !reflect.DeepEqual(got, want) {
+		t.Errorf("a.Merge(b) = %+v, want %+v", got, want)
+	}
+
+	// a set Order replaces the other one, and merging leaves both inputs alone
+	b.Order = []string{"sugar", "milk"}
+	if got := a.Merge(b); !slices.Equal(got.Order, b.Order) {
+		t.Errorf("Order = %v, want %v", got.Order, b.Order)
+	}
+	if a.MaxCount["sugar"] != 3 || len(a.Requires["sugar"]) != 1 || len(a.Exclusive) != 1 {
+		t.Errorf("Merge changed its receiver: %+v", a)
+	}
+}
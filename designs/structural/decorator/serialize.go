@@ -0,0 +1,366 @@
+package decorator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// A decorated Coffee is stored as its list of layers, innermost first. Each layer is saved as a registered type name plus
+// optional JSON data, so a chain such as SimpleCoffee + milk + oat-milk becomes
+//
+//	{"version":1,"layers":[{"type":"simple"},{"type":"milk"},{"type":"addon","data":{"name":"oat-milk",...}}]}
+//
+// The binary form holds the same layers in a compact length-prefixed encoding. Custom decorators take part by registering
+// a codec with RegisterBase or RegisterLayer.
+
+const encodingVersion = 1
+
+// binaryMagic starts every binary encoded chain
+var binaryMagic = []byte("CFE")
+
+// UnregisteredTypeError is returned when a layer's Go type has no codec
+type UnregisteredTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnregisteredTypeError) Error() string {
+	return fmt.Sprintf("decorator: no codec registered for %s", e.Type)
+}
+
+// UnknownLayerError is returned when an encoded layer name has no codec
+type UnknownLayerError struct {
+	Name string
+}
+
+func (e *UnknownLayerError) Error() string {
+	return fmt.Sprintf("decorator: no codec registered for layer %q", e.Name)
+}
+
+// ErrMalformedChain is returned for encoded chains that cannot be decoded
+var ErrMalformedChain = errors.New("decorator: malformed encoded chain")
+
+type codec struct {
+	name   string
+	base   bool
+	encode func(Coffee) ([]byte, error)
+	decode func(data []byte, inner Coffee) (Coffee, error)
+}
+
+// Registry maps layer types to codecs. It is safe for concurrent use.
+type Registry struct {
+	mu     sync.RWMutex
+	byType map[reflect.Type]*codec
+	byName map[string]*codec
+	clock  Clock
+}
+
+// RegistryOption configures a Registry
+type RegistryOption func(*Registry)
+
+// WithClock sets the clock given to decoded layers that read the time, such as happy hours. Defaults to SystemClock.
+func WithClock(clock Clock) RegistryOption {
+	return func(r *Registry) {
+		r.clock = clock
+	}
+}
+
+// NewRegistry creates a registry that knows SimpleCoffee, MilkDecorator, SugarDecorator, AddOnDecorator and the
+// pricing decorators
+func NewRegistry(opts ...RegistryOption) *Registry {
+	r := &Registry{byType: make(map[reflect.Type]*codec), byName: make(map[string]*codec), clock: SystemClock}
+	for _, opt := range opts {
+		opt(r)
+	}
+	RegisterBase(r, "simple", encodeSimple, decodeSimple)
+
+	RegisterLayer(r, "milk", nil, func(_ []byte, inner Coffee) (*MilkDecorator, error) {
+		return NewMilkDecorator(inner), nil
+	})
+	RegisterLayer(r, "sugar", nil, func(_ []byte, inner Coffee) (*SugarDecorator, error) {
+		return NewSugarDecorator(inner), nil
+	})
+	RegisterLayer(r, "addon", encodeAddOn, decodeAddOn)
+	registerPricing(r)
+	return r
+}
+
+// encodeSimple stores the currency only when it is not the default, so USD coffees encode as before
+func encodeSimple(c *SimpleCoffee) ([]byte, error) {
+	if c.Currency == "" {
+		return nil, nil
+	}
+	return json.Marshal(struct {
+		Currency Currency `json:"currency"`
+	}{c.Currency})
+}
+
+func decodeSimple(data []byte) (*SimpleCoffee, error) {
+	c := &SimpleCoffee{}
+	if len(data) == 0 {
+		return c, nil
+	}
+	var v struct {
+		Currency Currency `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	if _, ok := DefaultPrices[v.Currency]; !ok {
+		return nil, fmt.Errorf("decorator: no built-in prices in %s", v.Currency)
+	}
+	c.Currency = v.Currency
+	return c, nil
+}
+
+// DefaultRegistry is the registry used by EncodeJSON, DecodeJSON, EncodeBinary and DecodeBinary
+var DefaultRegistry = NewRegistry()
+
+// RegisterBase registers a codec for an undecorated Coffee type. encode must return JSON, or nil when the type has no
+// state.
+func RegisterBase[C Coffee](r *Registry, name string, encode func(C) ([]byte, error), decode func(data []byte) (C, error)) {
+	r.register(reflect.TypeFor[C](), &codec{
+		name:   name,
+		base:   true,
+		encode: wrapEncode(encode),
+		decode: func(data []byte, _ Coffee) (Coffee, error) {
+			return decode(data)
+		},
+	})
+}
+
+// RegisterLayer registers a codec for a decorator type. encode must return JSON, or nil when the decorator has no state of
+// its own.
+func RegisterLayer[C Coffee](r *Registry, name string, encode func(C) ([]byte, error), decode func(data []byte, inner Coffee) (C, error)) {
+	r.register(reflect.TypeFor[C](), &codec{
+		name:   name,
+		encode: wrapEncode(encode),
+		decode: func(data []byte, inner Coffee) (Coffee, error) {
+			return decode(data, inner)
+		},
+	})
+}
+
+func wrapEncode[C Coffee](encode func(C) ([]byte, error)) func(Coffee) ([]byte, error) {
+	if encode == nil {
+		return func(Coffee) ([]byte, error) { return nil, nil }
+	}
+	return func(c Coffee) ([]byte, error) {
+		return encode(c.(C))
+	}
+}
+
+func (r *Registry) register(t reflect.Type, c *codec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.byName[c.name]; ok {
+		for typ, oc := range r.byType {
+			if oc == old {
+				delete(r.byType, typ)
+			}
+		}
+	}
+	r.byType[t] = c
+	r.byName[c.name] = c
+}
+
+// encodedLayer is one layer of an encoded chain
+type encodedLayer struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type encodedChain struct {
+	Version int            `json:"version"`
+	Layers  []encodedLayer `json:"layers"`
+}
+
+// encode lists the layers innermost first
+func (r *Registry) encode(c Coffee) ([]encodedLayer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	layers := Layers(c)
+	encoded := make([]encodedLayer, len(layers))
+	for i, layer := range layers {
+		cd, ok := r.byType[reflect.TypeOf(layer)]
+		if !ok {
+			return nil, &UnregisteredTypeError{Type: reflect.TypeOf(layer)}
+		}
+		data, err := cd.encode(layer)
+		if err != nil {
+			return nil, fmt.Errorf("decorator: encoding layer %q: %w", cd.name, err)
+		}
+		encoded[len(layers)-1-i] = encodedLayer{Type: cd.name, Data: data}
+	}
+	return encoded, nil
+}
+
+// decode rebuilds the chain from layers listed innermost first
+func (r *Registry) decode(layers []encodedLayer) (Coffee, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("%w: no layers", ErrMalformedChain)
+	}
+	var c Coffee
+	for i, l := range layers {
+		cd, ok := r.byName[l.Type]
+		if !ok {
+			return nil, &UnknownLayerError{Name: l.Type}
+		}
+		if cd.base != (i == 0) {
+			return nil, fmt.Errorf("%w: layer %q at position %d", ErrMalformedChain, l.Type, i)
+		}
+		var err error
+		if c, err = cd.decode(l.Data, c); err != nil {
+			return nil, fmt.Errorf("decorator: decoding layer %q: %w", l.Type, err)
+		}
+	}
+	return c, nil
+}
+
+// EncodeJSON encodes the chain as JSON
+func (r *Registry) EncodeJSON(c Coffee) ([]byte, error) {
+	layers, err := r.encode(c)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(encodedChain{Version: encodingVersion, Layers: layers})
+}
+
+// DecodeJSON decodes a chain encoded with EncodeJSON
+func (r *Registry) DecodeJSON(data []byte) (Coffee, error) {
+	var chain encodedChain
+	if err := json.Unmarshal(data, &chain); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedChain, err)
+	}
+	if chain.Version != encodingVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrMalformedChain, chain.Version)
+	}
+	return r.decode(chain.Layers)
+}
+
+// EncodeBinary encodes the chain as "CFE", a version byte, the number of layers, and then every layer's type name and
+// data, each prefixed with its length as a uvarint
+func (r *Registry) EncodeBinary(c Coffee) ([]byte, error) {
+	layers, err := r.encode(c)
+	if err != nil {
+		return nil, err
+	}
+	buf := append([]byte(nil), binaryMagic...)
+	buf = append(buf, encodingVersion)
+	buf = binary.AppendUvarint(buf, uint64(len(layers)))
+	for _, l := range layers {
+		buf = binary.AppendUvarint(buf, uint64(len(l.Type)))
+		buf = append(buf, l.Type...)
+		buf = binary.AppendUvarint(buf, uint64(len(l.Data)))
+		buf = append(buf, l.Data...)
+	}
+	return buf, nil
+}
+
+// DecodeBinary decodes a chain encoded with EncodeBinary
+func (r *Registry) DecodeBinary(data []byte) (Coffee, error) {
+	if !bytes.HasPrefix(data, binaryMagic) || len(data) < len(binaryMagic)+1 {
+		return nil, fmt.Errorf("%w: missing header", ErrMalformedChain)
+	}
+	if v := data[len(binaryMagic)]; v != encodingVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrMalformedChain, v)
+	}
+	rd := bytes.NewReader(data[len(binaryMagic)+1:])
+	readBytes := func() ([]byte, error) {
+		n, err := binary.ReadUvarint(rd)
+		if err != nil || n > uint64(rd.Len()) {
+			return nil, fmt.Errorf("%w: truncated data", ErrMalformedChain)
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(rd, b)
+		return b, err
+	}
+	count, err := binary.ReadUvarint(rd)
+	if err != nil || count > uint64(rd.Len()) {
+		return nil, fmt.Errorf("%w: truncated data", ErrMalformedChain)
+	}
+	layers := make([]encodedLayer, count)
+	for i := range layers {
+		name, err := readBytes()
+		if err != nil {
+			return nil, err
+		}
+		payload, err := readBytes()
+		if err != nil {
+			return nil, err
+		}
+		layers[i] = encodedLayer{Type: string(name)}
+		if len(payload) > 0 {
+			layers[i].Data = payload
+		}
+	}
+	if rd.Len() != 0 {
+		return nil, fmt.Errorf("%w: trailing data", ErrMalformedChain)
+	}
+	return r.decode(layers)
+}
+
+// EncodeJSON encodes the chain with the DefaultRegistry
+func EncodeJSON(c Coffee) ([]byte, error) {
+	return DefaultRegistry.EncodeJSON(c)
+}
+
+// DecodeJSON decodes a chain with the DefaultRegistry
+func DecodeJSON(data []byte) (Coffee, error) {
+	return DefaultRegistry.DecodeJSON(data)
+}
+
+// EncodeBinary encodes the chain with the DefaultRegistry
+func EncodeBinary(c Coffee) ([]byte, error) {
+	return DefaultRegistry.EncodeBinary(c)
+}
+
+// DecodeBinary decodes a chain with the DefaultRegistry
+func DecodeBinary(data []byte) (Coffee, error) {
+	return DefaultRegistry.DecodeBinary(data)
+}
+
+// addOnJSON is the stored form of a catalog add-on. The whole add-on is kept, not just its name, so a saved order
+// prices the same even after the menu changes.
+type addOnJSON struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Price       Money    `json:"price"`
+	MaxQuantity int      `json:"max_quantity,omitempty"`
+	Excludes    []string `json:"excludes,omitempty"`
+	Requires    []string `json:"requires,omitempty"`
+}
+
+func encodeAddOn(d *AddOnDecorator) ([]byte, error) {
+	a := d.addOn
+	return json.Marshal(addOnJSON{
+		Name:        a.Name,
+		Description: a.Description,
+		Price:       a.Price,
+		MaxQuantity: a.MaxQuantity,
+		Excludes:    a.Excludes,
+		Requires:    a.Requires,
+	})
+}
+
+func decodeAddOn(data []byte, inner Coffee) (*AddOnDecorator, error) {
+	var a addOnJSON
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, err
+	}
+	return NewAddOnDecorator(inner, &AddOn{
+		Name:        a.Name,
+		Description: a.Description,
+		Price:       a.Price,
+		MaxQuantity: a.MaxQuantity,
+		Excludes:    a.Excludes,
+		Requires:    a.Requires,
+	}), nil
+}
@@ -0,0 +1,133 @@
+package decorator
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func serializeCases() map[string]Coffee {
+	oat := &AddOn{Name: "oat-milk", Description: "oat milk", Price: NewMoney(75, "USD"), MaxQuantity: 1, Excludes: []string{"milk"}}
+	allDay := TimeWindow{Start: 0, End: 24 * time.Hour}
+	return map[string]Coffee{
+		"simple":     &SimpleCoffee{},
+		"milk":       NewMilkDecorator(&SimpleCoffee{}),
+		"sugar":      NewSugarDecorator(&SimpleCoffee{}),
+		"addon":      NewAddOnDecorator(&SimpleCoffee{}, oat),
+		"discount":   NewPercentDiscount(NewMilkDecorator(&SimpleCoffee{}), 1250),
+		"happy-hour": NewHappyHourDecorator(&SimpleCoffee{}, allDay, 20*Percent, SystemClock),
+		"size":       NewSizeDecorator(NewSugarDecorator(&SimpleCoffee{}), Large),
+		"promo":      NewPromoDecorator(&SimpleCoffee{}, Promo{Code: "SAVE", Rate: 10 * Percent, Amount: NewMoney(25, "USD")}),
+		"everything": NewPromoDecorator(
+			NewHappyHourDecorator(
+				NewSizeDecorator(NewAddOnDecorator(NewMilkDecorator(&SimpleCoffee{}), oat), Medium),
+				allDay, 15*Percent, SystemClock),
+			Promo{Code: "TEN", Amount: NewMoney(10, "USD")}),
+	}
+}
+
+func TestSerializeRoundTrip(t *testing.T) {
+	formats := map[string]struct {
+		encode func(Coffee) ([]byte, error)
+		decode func([]byte) (Coffee, error)
+	}{
+		"json":   {EncodeJSON, DecodeJSON},
+		"binary": {EncodeBinary, DecodeBinary},
+	}
+	for format, f := range formats {
+		for name, c := range serializeCases() {
+			t.Run(format+"/"+name, func(t *testing.T) {
+				data, err := f.encode(c)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := f.decode(data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Cost() != c.Cost() {
+					t.Errorf("Cost() = %v, want %v", got.Cost(), c.Cost())
+				}
+				if got.Description() != c.Description() {
+					t.Errorf("Description() = %q, want %q", got.Description(), c.Description())
+				}
+				if len(Layers(got)) != len(Layers(c)) {
+					t.Errorf("got %d layers, want %d", len(Layers(got)), len(Layers(c)))
+				}
+			})
+		}
+	}
+}
+
+func TestDecodeBinaryCorrupt(t *testing.T) {
+	data, err := EncodeBinary(serializeCases()["everything"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := range len(data) {
+		if _, err := DecodeBinary(data[:n]); err == nil {
+			t.Errorf("DecodeBinary of %d of %d bytes succeeded", n, len(data))
+		}
+	}
+
+	corrupt := map[string][]byte{
+		"bad magic":     append([]byte("XYZ"), data[3:]...),
+		"bad version":   append(append([]byte("CFE"), 99), data[4:]...),
+		"trailing data": append(append([]byte(nil), data...), 0),
+		"huge count":    {'C', 'F', 'E', encodingVersion, 0xff, 0xff, 0xff, 0xff, 0x0f},
+		"no layers":     {'C', 'F', 'E', encodingVersion, 0},
+	}
+	for name, b := range corrupt {
+		_, err := DecodeBinary(b)
+		if !errors.Is(err, ErrMalformedChain) {
+			t.Errorf("%s: err = %v, want ErrMalformedChain", name, err)
+		}
+	}
+}
+
+func TestDecodeUnknownLayer(t *testing.T) {
+	_, err := DecodeJSON([]byte(`{"version":1,"layers":[{"type":"simple"},{"type":"whipped-cream"}]}`))
+	var unknown *UnknownLayerError
+	if !errors.As(err, &unknown) || unknown.Name != "whipped-cream" {
+		t.Errorf("JSON: err = %v, want UnknownLayerError for whipped-cream", err)
+	}
+
+	data := []byte{'C', 'F', 'E', encodingVersion, 2, 6}
+	data = append(data, "simple"...)
+	data = append(data, 0, 4)
+	data = append(data, "foam"...)
+	data = append(data, 0)
+	_, err = DecodeBinary(data)
+	if !errors.As(err, &unknown) || unknown.Name != "foam" {
+		t.Errorf("binary: err = %v, want UnknownLayerError for foam", err)
+	}
+}
+
+func TestDecodeMisplacedBase(t *testing.T) {
+	_, err := DecodeJSON([]byte(`{"version":1,"layers":[{"type":"milk"},{"type":"simple"}]}`))
+	if !errors.Is(err, ErrMalformedChain) {
+		t.Errorf("err = %v, want ErrMalformedChain", err)
+	}
+}
+
+func TestDecodeHappyHourUsesRegistryClock(t *testing.T) {
+	clock := newFakeClock() // 12:00
+	r := NewRegistry(WithClock(clock))
+	window := TimeWindow{Start: 15 * time.Hour, End: 17 * time.Hour}
+	data, err := r.EncodeJSON(NewHappyHourDecorator(&SimpleCoffee{}, window, 20*Percent, SystemClock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := r.DecodeJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := (&SimpleCoffee{}).Cost()
+	if c.Cost() != full {
+		t.Errorf("Cost() = %v outside the window, want %v", c.Cost(), full)
+	}
+	clock.Advance(4 * time.Hour)
+	if want := (20 * Percent).off(full); c.Cost() != want {
+		t.Errorf("Cost() = %v inside the window, want %v", c.Cost(), want)
+	}
+}
@@ -0,0 +1,339 @@
+package decorator
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"hash"
+	"io"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// io.Reader and io.Writer are Go's own Component interfaces: gzip.NewWriter, base64.NewEncoder and bufio.NewReader are
+// all ConcreteDecorators that wrap one and return another. The stacks below assemble those decorators in data-flow order,
+// so the first layer added is the first one to see the data:
+//
+//	w := NewWriterStack().CountLines(lines).Gzip().Base64().Build(dst)  // count, then compress, then encode
+//	r := NewReaderStack().Base64Decode().Gunzip().CountLines(lines).Build(src) // decode, then decompress, then count
+//
+// Writers are layered as io.WriteClosers. Closing the built writer flushes every layer (gzip and base64 buffer their
+// output) from the outside in; dst itself is never closed.
+
+// WriterStack builds a stack of writer decorators
+type WriterStack struct {
+	layers []Decorator[io.WriteCloser]
+}
+
+// NewWriterStack creates an empty writer stack
+func NewWriterStack() *WriterStack {
+	return &WriterStack{}
+}
+
+// Use adds a custom layer
+func (s *WriterStack) Use(d Decorator[io.WriteCloser]) *WriterStack {
+	s.layers = append(s.layers, d)
+	return s
+}
+
+// Gzip compresses the data
+func (s *WriterStack) Gzip() *WriterStack {
+	return s.Use(func(next io.WriteCloser) io.WriteCloser {
+		return &flushingWriter{Writer: gzip.NewWriter(next), next: next}
+	})
+}
+
+// Base64 encodes the data with standard base64
+func (s *WriterStack) Base64() *WriterStack {
+	return s.Use(func(next io.WriteCloser) io.WriteCloser {
+		return &flushingWriter{Writer: base64.NewEncoder(base64.StdEncoding, next), next: next}
+	})
+}
+
+// Checksum feeds every byte passing through into the hash
+func (s *WriterStack) Checksum(h hash.Hash) *WriterStack {
+	return s.Use(func(next io.WriteCloser) io.WriteCloser {
+		return &flushingWriter{Writer: io.MultiWriter(h, next), next: next}
+	})
+}
+
+// RateLimit paces writes with the limiter
+func (s *WriterStack) RateLimit(l *RateLimiter) *WriterStack {
+	return s.Use(func(next io.WriteCloser) io.WriteCloser {
+		return &flushingWriter{Writer: &rateLimitedWriter{l, next}, next: next}
+	})
+}
+
+// Progress calls report with the total number of bytes written so far after every write
+func (s *WriterStack) Progress(report func(total int64)) *WriterStack {
+	return s.Use(func(next io.WriteCloser) io.WriteCloser {
+		return &flushingWriter{Writer: &progressWriter{next: next, report: report}, next: next}
+	})
+}
+
+// CountLines counts the lines passing through
+func (s *WriterStack) CountLines(c *LineCounter) *WriterStack {
+	return s.Use(func(next io.WriteCloser) io.WriteCloser {
+		return &flushingWriter{Writer: io.MultiWriter(c, next), next: next}
+	})
+}
+
+// Build stacks the layers on top of dst
+func (s *WriterStack) Build(dst io.Writer) io.WriteCloser {
+	// the first layer sees the data first, so it has to be the outermost one
+	layers := make([]Decorator[io.WriteCloser], len(s.layers))
+	for i, d := range s.layers {
+		layers[len(s.layers)-1-i] = d
+	}
+	return NewChain(layers...).Apply(nopWriteCloser{dst})
+}
+
+// flushingWriter closes its own writer, if it is a Closer, and then the next layer
+type flushingWriter struct {
+	io.Writer
+	next io.WriteCloser
+}
+
+func (w *flushingWriter) Close() error {
+	var err error
+	if c, ok := w.Writer.(io.Closer); ok {
+		err = c.Close()
+	}
+	if cerr := w.next.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// ReaderStack builds a stack of reader decorators
+type ReaderStack struct {
+	layers []Decorator[io.Reader]
+}
+
+// NewReaderStack creates an empty reader stack
+func NewReaderStack() *ReaderStack {
+	return &ReaderStack{}
+}
+
+// Use adds a custom layer
+func (s *ReaderStack) Use(d Decorator[io.Reader]) *ReaderStack {
+	s.layers = append(s.layers, d)
+	return s
+}
+
+// Gunzip decompresses the data. The gzip header is read on the first call to Read, so a bad header is reported there.
+func (s *ReaderStack) Gunzip() *ReaderStack {
+	return s.Use(func(next io.Reader) io.Reader {
+		return &gunzipReader{next: next}
+	})
+}
+
+// Base64Decode decodes standard base64
+func (s *ReaderStack) Base64Decode() *ReaderStack {
+	return s.Use(func(next io.Reader) io.Reader {
+		return base64.NewDecoder(base64.StdEncoding, next)
+	})
+}
+
+// Checksum feeds every byte passing through into the hash
+func (s *ReaderStack) Checksum(h hash.Hash) *ReaderStack {
+	return s.Use(func(next io.Reader) io.Reader {
+		return io.TeeReader(next, h)
+	})
+}
+
+// RateLimit paces reads with the limiter
+func (s *ReaderStack) RateLimit(l *RateLimiter) *ReaderStack {
+	return s.Use(func(next io.Reader) io.Reader {
+		return &rateLimitedReader{l, next}
+	})
+}
+
+// Progress calls report with the total number of bytes read so far after every read
+func (s *ReaderStack) Progress(report func(total int64)) *ReaderStack {
+	return s.Use(func(next io.Reader) io.Reader {
+		return &progressReader{next: next, report: report}
+	})
+}
+
+// CountLines counts the lines passing through
+func (s *ReaderStack) CountLines(c *LineCounter) *ReaderStack {
+	return s.Use(func(next io.Reader) io.Reader {
+		return io.TeeReader(next, c)
+	})
+}
+
+// Build stacks the layers on top of src
+func (s *ReaderStack) Build(src io.Reader) io.Reader {
+	return NewChain(s.layers...).Apply(src)
+}
+
+type gunzipReader struct {
+	next io.Reader
+	gz   *gzip.Reader
+	err  error
+}
+
+func (r *gunzipReader) Read(p []byte) (int, error) {
+	if r.gz == nil && r.err == nil {
+		r.gz, r.err = gzip.NewReader(r.next)
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.gz.Read(p)
+}
+
+// LineCounter counts newline-terminated lines plus a final unterminated one. It is safe for concurrent use.
+type LineCounter struct {
+	mu      sync.Mutex
+	lines   int64
+	partial bool
+}
+
+func (c *LineCounter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	c.mu.Lock()
+	c.lines += int64(bytes.Count(p, []byte{'\n'}))
+	c.partial = p[len(p)-1] != '\n'
+	c.mu.Unlock()
+	return len(p), nil
+}
+
+// Lines returns the number of lines seen so far
+func (c *LineCounter) Lines() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.partial {
+		return c.lines + 1
+	}
+	return c.lines
+}
+
+// RateLimiter allows a number of bytes per second. One limiter can be shared by several layers to cap their total.
+// It is a token bucket that holds one second's worth of bytes, so after an idle period at most that much goes through
+// without waiting. It is safe for concurrent use.
+type RateLimiter struct {
+	mu             sync.Mutex
+	bytesPerSecond int64
+	clock          Clock
+	sleep          func(time.Duration)
+	// due is when every byte handed out so far is paid for. Keeping it no more than a second behind the clock is what
+	// caps the burst.
+	due time.Time
+}
+
+// NewRateLimiter creates a limiter. A nil clock uses SystemClock and a nil sleep uses time.Sleep.
+func NewRateLimiter(bytesPerSecond int64, clock Clock, sleep func(time.Duration)) *RateLimiter {
+	if clock == nil {
+		clock = SystemClock
+	}
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	return &RateLimiter{bytesPerSecond: max(bytesPerSecond, 1), clock: clock, sleep: sleep}
+}
+
+// chunk returns how many bytes of n may be sent in one go
+func (l *RateLimiter) chunk(n int) int {
+	return int(min(int64(n), l.bytesPerSecond))
+}
+
+// cost returns how long n bytes take at the limiter's rate. The product is worked out in 128 bits so it cannot
+// overflow; n is at most bytesPerSecond, so the quotient fits.
+func (l *RateLimiter) cost(n int) time.Duration {
+	hi, lo := bits.Mul64(uint64(n), uint64(time.Second))
+	q, _ := bits.Div64(hi, lo, uint64(l.bytesPerSecond))
+	return time.Duration(q)
+}
+
+// wait spends n bytes of the budget and blocks until they are due. The lock is only held to book the bytes, so other
+// callers queue up behind this one instead of behind its sleep.
+func (l *RateLimiter) wait(n int) {
+	l.mu.Lock()
+	now := l.clock.Now()
+	if full := now.Add(-time.Second); l.due.Before(full) {
+		l.due = full
+	}
+	l.due = l.due.Add(l.cost(n))
+	d := l.due.Sub(now)
+	l.mu.Unlock()
+	if d > 0 {
+		l.sleep(d)
+	}
+}
+
+type rateLimitedWriter struct {
+	limiter *RateLimiter
+	next    io.Writer
+}
+
+func (w *rateLimitedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := w.limiter.chunk(len(p))
+		w.limiter.wait(n)
+		m, err := w.next.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+type rateLimitedReader struct {
+	limiter *RateLimiter
+	next    io.Reader
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	n, err := r.next.Read(p[:r.limiter.chunk(len(p))])
+	if n > 0 {
+		r.limiter.wait(n)
+	}
+	return n, err
+}
+
+type progressWriter struct {
+	next   io.Writer
+	total  int64
+	report func(int64)
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.next.Write(p)
+	w.total += int64(n)
+	w.report(w.total)
+	return n, err
+}
+
+type progressReader struct {
+	next   io.Reader
+	total  int64
+	report func(int64)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.next.Read(p)
+	if n > 0 {
+		r.total += int64(n)
+		r.report(r.total)
+	}
+	return n, err
+}
@@ -0,0 +1,211 @@
+package decorator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when told to
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestStreamGzipBase64RoundTrip(t *testing.T) {
+	input := strings.Repeat("the quick brown fox jumps over the lazy dog\n", 200)
+	var encoded bytes.Buffer
+	w := NewWriterStack().Gzip().Base64().Build(&encoded)
+	if _, err := io.WriteString(w, input); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.ContainsAny(encoded.String(), "\x00\x1f") || encoded.Len() >= len(input) {
+		t.Errorf("output does not look compressed and encoded: %d bytes", encoded.Len())
+	}
+
+	r := NewReaderStack().Base64Decode().Gunzip().Build(&encoded)
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != input {
+		t.Errorf("round trip changed the data: got %d bytes, want %d", len(got), len(input))
+	}
+}
+
+func TestStreamChecksumMatches(t *testing.T) {
+	input := strings.Repeat("checksum me\n", 100)
+	written, read := sha256.New(), sha256.New()
+	var encoded bytes.Buffer
+	w := NewWriterStack().Checksum(written).Gzip().Build(&encoded)
+	io.WriteString(w, input)
+	w.Close()
+
+	r := NewReaderStack().Gunzip().Checksum(read).Build(&encoded)
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(written.Sum(nil), read.Sum(nil)) {
+		t.Error("checksums of the written and read data differ")
+	}
+	if want := sha256.Sum256([]byte(input)); !bytes.Equal(read.Sum(nil), want[:]) {
+		t.Error("checksum is not over the plain data")
+	}
+}
+
+func TestStreamCountLines(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int64
+	}{
+		{"", 0},
+		{"one\ntwo\n", 2},
+		{"one\ntwo", 2},
+		{"one\n\nthree", 3},
+	}
+	for _, tt := range tests {
+		var lines LineCounter
+		w := NewWriterStack().CountLines(&lines).Build(io.Discard)
+		io.WriteString(w, tt.input)
+		w.Close()
+		if got := lines.Lines(); got != tt.want {
+			t.Errorf("writer: %q has %d lines, want %d", tt.input, got, tt.want)
+		}
+
+		var readLines LineCounter
+		io.Copy(io.Discard, NewReaderStack().CountLines(&readLines).Build(strings.NewReader(tt.input)))
+		if got := readLines.Lines(); got != tt.want {
+			t.Errorf("reader: %q has %d lines, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestStreamProgress(t *testing.T) {
+	var reports []int64
+	w := NewWriterStack().Progress(func(total int64) { reports = append(reports, total) }).Build(io.Discard)
+	io.WriteString(w, "abc")
+	io.WriteString(w, "defgh")
+	w.Close()
+	if len(reports) != 2 || reports[0] != 3 || reports[1] != 8 {
+		t.Errorf("reports = %v, want [3 8]", reports)
+	}
+
+	var readReports []int64
+	r := NewReaderStack().Progress(func(total int64) { readReports = append(readReports, total) }).Build(strings.NewReader("0123456789"))
+	io.ReadAll(r)
+	if len(readReports) == 0 || readReports[len(readReports)-1] != 10 {
+		t.Errorf("read reports = %v, want a final total of 10", readReports)
+	}
+}
+
+func TestRateLimiterWithFakeClock(t *testing.T) {
+	clock := newFakeClock()
+	var slept time.Duration
+	sleep := func(d time.Duration) {
+		slept += d
+		clock.Advance(d)
+	}
+	limiter := NewRateLimiter(100, clock, sleep)
+
+	var out bytes.Buffer
+	w := NewWriterStack().RateLimit(limiter).Build(&out)
+	if _, err := w.Write(make([]byte, 350)); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if out.Len() != 350 {
+		t.Errorf("wrote %d bytes, want 350", out.Len())
+	}
+	// the bucket starts full, so the first second's worth goes through straight away
+	if slept != 2500*time.Millisecond {
+		t.Errorf("slept %v, want 2.5s for 350 bytes at 100 B/s with a 100 byte burst", slept)
+	}
+
+	// time that passed on its own refills the bucket
+	clock.Advance(10 * time.Second)
+	slept = 0
+	r := NewReaderStack().RateLimit(limiter).Build(bytes.NewReader(make([]byte, 50)))
+	io.ReadAll(r)
+	if slept != 0 {
+		t.Errorf("slept %v after an idle period, want 0", slept)
+	}
+
+	// but only up to one second's worth: ten idle seconds do not buy ten seconds of bytes
+	clock.Advance(10 * time.Second)
+	slept = 0
+	w.Write(make([]byte, 350))
+	if slept != 2500*time.Millisecond {
+		t.Errorf("slept %v after a long idle period, want 2.5s", slept)
+	}
+}
+
+func TestRateLimiterHugeRate(t *testing.T) {
+	clock := newFakeClock()
+	var slept time.Duration
+	limiter := NewRateLimiter(1<<40, clock, func(d time.Duration) {
+		slept += d
+		clock.Advance(d)
+	})
+	// 10 * 2^40 bytes, where bytes * time.Second no longer fits in a Duration
+	for range 10 {
+		limiter.wait(limiter.chunk(1 << 40))
+	}
+	if slept != 9*time.Second {
+		t.Errorf("slept %v, want 9s", slept)
+	}
+}
+
+func TestRateLimiterSleepsWithoutLock(t *testing.T) {
+	clock := newFakeClock()
+	var first atomic.Bool
+	var limiter *RateLimiter
+	limiter = NewRateLimiter(10, clock, func(d time.Duration) {
+		if !first.CompareAndSwap(false, true) {
+			return
+		}
+		// another caller must be able to book its bytes while this one sleeps
+		done := make(chan struct{})
+		go func() {
+			limiter.wait(10)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Error("a second caller blocked while the first one slept")
+		}
+	})
+	limiter.wait(10)
+	limiter.wait(10)
+
+	// three seconds' worth was booked: one from the burst and two that have to wait
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if want := clock.Now().Add(2 * time.Second); !limiter.due.Equal(want) {
+		t.Errorf("due = %v, want %v", limiter.due, want)
+	}
+}
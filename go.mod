@@ -1,3 +1,5 @@
 module github.com/joshbrgs/dsa
 
 go 1.24.1
+
+require gopkg.in/yaml.v3 v3.0.1